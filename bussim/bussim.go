@@ -0,0 +1,442 @@
+// Package bussim simulates a 10BASE-T1S mixing segment in-process.
+//
+// Stations attach to a [Bus] via a [Port], either driven by an emulated
+// MAC-PHY, or directly by a [t1s.UpperProto]. Medium access is simulated
+// using PLCA, as configured per port, with transmit opportunities, beacons
+// sent by the coordinator (node ID 0), and burst mode, where a node keeps
+// its transmit opportunity as long as its next frame is ready within
+// BurstTimer bit times; ports without PLCA, or not receiving beacons,
+// use CSMA/CD with binary exponential backoff.
+//
+// Time is virtual; it advances only when [Bus.Advance] is called.
+// Frame transmission is modeled at the granularity of complete frames,
+// transmit opportunities and beacons, measured in bit times of 100 ns.
+package bussim
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/knieriem/t1s"
+)
+
+// BitTime is the duration of a bit at 10 Mbit/s.
+const BitTime = 100 * time.Nanosecond
+
+// Timing parameters, in bit times.
+const (
+	beaconBits    = 20
+	toTimerBits   = 32
+	ifgBits       = 96
+	preambleBits  = 64
+	jamBits       = 32
+	slotBits      = 512
+	invBeaconBits = 4000
+
+	maxAttempts = 16
+	maxBackoffK = 10
+)
+
+// DefaultQueueLen is the default number of frames
+// a port's transmit queue can hold.
+const DefaultQueueLen = 8
+
+// Stats contains counters of a bus.
+type Stats struct {
+	Cycles     uint64 // PLCA cycles
+	Frames     uint64 // frames transmitted successfully
+	Collisions uint64
+	BusyBits   uint64 // bit times the medium carried frames, beacons or jam
+}
+
+// Bus is a simulated mixing segment.
+type Bus struct {
+	mu    sync.Mutex
+	ports []*Port
+	now   uint64
+	rand  *rand.Rand
+	stats Stats
+
+	// PLCA cycle state
+	curID int
+	inTO  bool
+
+	// burst state: the port holding the medium while waiting
+	// for its next frame, the number of frames it may still
+	// send, and the time its burst timer expires
+	burst      *Port
+	burstLeft  int
+	burstUntil uint64
+}
+
+// New creates a new bus. Seed initializes the random number
+// generator used for CSMA/CD backoff.
+func New(seed int64) *Bus {
+	return &Bus{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Now returns the current simulation time.
+func (b *Bus) Now() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Duration(b.now) * BitTime
+}
+
+// Milliseconds returns the current simulation time in milliseconds, so that
+// a Bus may serve as ticks provider for drivers attached to it.
+func (b *Bus) Milliseconds() uint32 {
+	return uint32(b.Now() / time.Millisecond)
+}
+
+// Stats returns the bus counters.
+func (b *Bus) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Attach connects a new port to the bus. Frames received from other
+// ports are passed to recv, without FCS. The frame buffer passed to
+// recv must not be modified.
+func (b *Bus) Attach(name string, recv func(frame []byte)) *Port {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := &Port{
+		Name:     name,
+		QueueLen: DefaultQueueLen,
+		bus:      b,
+		recv:     recv,
+	}
+	b.ports = append(b.ports, p)
+	return p
+}
+
+// Detach disconnects p from the bus.
+func (b *Bus) Detach(p *Port) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, q := range b.ports {
+		if q == p {
+			b.ports = append(b.ports[:i], b.ports[i+1:]...)
+			break
+		}
+	}
+}
+
+// Advance runs the simulation for duration d.
+func (b *Bus) Advance(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	end := b.now + uint64(d/BitTime)
+	for b.now < end {
+		b.pollUpper()
+		b.updatePLCAStatus()
+		coord := b.coordinator()
+		if coord == nil {
+			b.burst = nil
+			b.stepCSMA(end)
+			continue
+		}
+		b.stepPLCA(coord, end)
+	}
+}
+
+// coordinator returns the port acting as PLCA coordinator, if any.
+func (b *Bus) coordinator() *Port {
+	for _, p := range b.ports {
+		if p.plca != nil && p.plca.NodeID == 0 {
+			return p
+		}
+	}
+	return nil
+}
+
+func (b *Bus) updatePLCAStatus() {
+	for _, p := range b.ports {
+		if p.plca == nil {
+			p.plcaActive = false
+			continue
+		}
+		if p.plca.NodeID != 0 && b.now-p.lastBeacon > invBeaconBits {
+			p.plcaActive = false
+		}
+	}
+}
+
+func (b *Bus) stepPLCA(coord *Port, end uint64) {
+	if b.burst != nil {
+		if b.stepBurst(end) {
+			b.nextTO(coord)
+		}
+		return
+	}
+	if !b.inTO {
+		// beacon
+		b.busy(beaconBits)
+		for _, p := range b.ports {
+			if p.plca != nil {
+				p.plcaActive = true
+				p.lastBeacon = b.now
+			}
+		}
+		b.curID = 0
+		b.inTO = true
+		b.stats.Cycles++
+		return
+	}
+	id := b.curID
+	var tx []*Port
+	for _, p := range b.ports {
+		if !p.ready(b.now) {
+			continue
+		}
+		if p.plcaActive && int(p.plca.NodeID) == id || !p.plcaActive {
+			tx = append(tx, p)
+		}
+	}
+	switch len(tx) {
+	case 0:
+		b.now += toTimerBits
+	case 1:
+		p := tx[0]
+		b.transmit(p)
+		if p.plcaActive && b.startBurst(p, int(p.plca.BurstCount)) {
+			return
+		}
+	default:
+		b.collide(tx)
+	}
+	b.nextTO(coord)
+}
+
+// nextTO passes the transmit opportunity to the next node ID.
+func (b *Bus) nextTO(coord *Port) {
+	b.curID++
+	n := int(coord.plca.NodeCount)
+	if n == 0 {
+		n = 1
+	}
+	if b.curID >= n {
+		b.inTO = false
+	}
+}
+
+// startBurst lets p, after having sent a frame, keep the medium
+// for up to BurstTimer bit times, counted from the end of the frame,
+// including the inter-frame gap, so that it may send up to left
+// further frames. It reports whether a burst has been started.
+func (b *Bus) startBurst(p *Port, left int) bool {
+	until := b.now - ifgBits + uint64(p.plca.BurstTimer)
+	if left == 0 || until <= b.now {
+		b.burst = nil
+		return false
+	}
+	b.burst = p
+	b.burstLeft = left
+	b.burstUntil = until
+	return true
+}
+
+// stepBurst transmits the next frame of a burst, if it is ready,
+// or lets the burst timer run, while the node keeps the medium
+// busy by signaling COMMIT. It reports whether the burst has ended.
+func (b *Bus) stepBurst(end uint64) bool {
+	p := b.burst
+	switch {
+	case !p.plcaActive:
+	case p.ready(b.now):
+		b.transmit(p)
+		return !b.startBurst(p, b.burstLeft-1)
+	case b.now < b.burstUntil:
+		b.busy(min(b.burstUntil, end) - b.now)
+		return false
+	}
+	b.burst = nil
+	return true
+}
+
+func (b *Bus) stepCSMA(end uint64) {
+	var tx []*Port
+	for _, p := range b.ports {
+		if p.ready(b.now) {
+			tx = append(tx, p)
+		}
+	}
+	switch len(tx) {
+	case 0:
+		// Skip to the next backoff expiry, if any.
+		next := end
+		for _, p := range b.ports {
+			if len(p.queue) != 0 && p.backoffUntil > b.now && p.backoffUntil < next {
+				next = p.backoffUntil
+			}
+		}
+		b.now = next
+	case 1:
+		b.transmit(tx[0])
+	default:
+		b.collide(tx)
+	}
+}
+
+func (b *Bus) busy(bits uint64) {
+	b.now += bits
+	b.stats.BusyBits += bits
+}
+
+func (b *Bus) transmit(p *Port) {
+	f := p.queue[0]
+	p.queue = p.queue[1:]
+	p.attempts = 0
+	b.busy(preambleBits + uint64(len(f)+t1s.FCSLen)*8)
+	b.now += ifgBits
+	b.stats.Frames++
+	p.stats.TxFrames++
+
+	// Callbacks are run without holding the lock, so that they
+	// may use Port methods, or attach and detach ports.
+	for _, q := range b.snapshot() {
+		if q == p || q.recv == nil {
+			continue
+		}
+		q.stats.RxFrames++
+		b.mu.Unlock()
+		q.recv(f)
+		b.mu.Lock()
+	}
+}
+
+// snapshot returns a copy of the list of ports,
+// to be iterated while the lock is released.
+func (b *Bus) snapshot() []*Port {
+	return append([]*Port(nil), b.ports...)
+}
+
+func (b *Bus) collide(tx []*Port) {
+	b.busy(preambleBits + jamBits)
+	b.now += ifgBits
+	b.stats.Collisions++
+	for _, p := range tx {
+		p.stats.Collisions++
+		if p.plcaActive {
+			// Collision detection is disabled in PLCA mode;
+			// the frame is lost without the sender noticing.
+			p.queue = p.queue[1:]
+			p.stats.Lost++
+			continue
+		}
+		p.attempts++
+		if p.attempts >= maxAttempts {
+			p.queue = p.queue[1:]
+			p.attempts = 0
+			p.stats.Lost++
+			continue
+		}
+		k := min(p.attempts, maxBackoffK)
+		p.backoffUntil = b.now + uint64(b.rand.Intn(1<<k))*slotBits
+	}
+}
+
+func (b *Bus) pollUpper() {
+	for _, p := range b.snapshot() {
+		if p.upper != nil {
+			p.pollUpper()
+		}
+	}
+}
+
+// PortStats contains counters of a port.
+type PortStats struct {
+	TxFrames   uint64
+	RxFrames   uint64
+	Collisions uint64
+	Lost       uint64 // frames lost due to collisions
+	Dropped    uint64 // frames dropped because the queue was full
+}
+
+// Port is the attachment of a station to a bus.
+type Port struct {
+	Name string
+
+	// QueueLen limits the number of frames waiting for transmission.
+	QueueLen int
+
+	bus  *Bus
+	recv func(frame []byte)
+
+	plca       *t1s.PLCAConf
+	plcaActive bool
+	lastBeacon uint64
+
+	queue        [][]byte
+	attempts     int
+	backoffUntil uint64
+	stats        PortStats
+
+	upper   t1s.UpperProto
+	filter  *t1s.MACConf
+	pollBuf []byte
+	rxBuf   []byte
+}
+
+// Configure sets the PLCA configuration of p. If plca is nil,
+// CSMA/CD is used.
+func (p *Port) Configure(plca *t1s.PLCAConf) {
+	p.bus.mu.Lock()
+	defer p.bus.mu.Unlock()
+	if plca != nil {
+		c := *plca
+		plca = &c
+	}
+	p.plca = plca
+	p.plcaActive = false
+}
+
+// PLCAStatus reports whether PLCA is enabled at p, and beacons are received.
+func (p *Port) PLCAStatus() bool {
+	p.bus.mu.Lock()
+	defer p.bus.mu.Unlock()
+	return p.plcaActive
+}
+
+// Send queues a copy of frame, which must not contain an FCS,
+// for transmission. Frames shorter than the minimum size are padded.
+// It reports false if the queue is full.
+func (p *Port) Send(frame []byte) bool {
+	p.bus.mu.Lock()
+	defer p.bus.mu.Unlock()
+	return p.enqueue(frame)
+}
+
+func (p *Port) enqueue(frame []byte) bool {
+	if len(p.queue) >= p.QueueLen {
+		p.stats.Dropped++
+		return false
+	}
+	f := make([]byte, max(len(frame), t1s.MinPaddedLen))
+	copy(f, frame)
+	p.queue = append(p.queue, f)
+	return true
+}
+
+// Pending returns the number of frames waiting for transmission.
+func (p *Port) Pending() int {
+	p.bus.mu.Lock()
+	defer p.bus.mu.Unlock()
+	return len(p.queue)
+}
+
+// Stats returns the port's counters.
+func (p *Port) Stats() PortStats {
+	p.bus.mu.Lock()
+	defer p.bus.mu.Unlock()
+	return p.stats
+}
+
+func (p *Port) ready(now uint64) bool {
+	if len(p.queue) == 0 || p.backoffUntil > now {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+package bussim_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+)
+
+// frame returns a minimum size frame, with the sender's
+// number as the last byte of the source address.
+func frame(src byte) []byte {
+	f := make([]byte, t1s.MinPaddedLen)
+	copy(f, t1s.BroadcastAddr[:])
+	f[11] = src
+	return f
+}
+
+// Duration of a minimum size frame on the medium, including
+// preamble and FCS, and of the following inter-frame gap, in bit times.
+const (
+	frameBits = 64 + (t1s.MinPaddedLen+t1s.FCSLen)*8
+	ifgBits   = 96
+)
+
+// segment is a bus with a listener, recording
+// the senders and times of received frames.
+type segment struct {
+	bus   *bussim.Bus
+	nodes map[byte]*bussim.Port
+	srcs  []byte
+	times []time.Duration
+}
+
+func newSegment() *segment {
+	s := &segment{bus: bussim.New(1), nodes: make(map[byte]*bussim.Port)}
+	s.bus.Attach("listener", func(f []byte) {
+		s.srcs = append(s.srcs, f[11])
+		s.times = append(s.times, s.bus.Now())
+	})
+	return s
+}
+
+// node attaches a node; if plca is nil, it uses CSMA/CD.
+func (s *segment) node(n byte, plca *t1s.PLCAConf) *bussim.Port {
+	p := s.bus.Attach(string('0'+n), nil)
+	p.Configure(plca)
+	s.nodes[n] = p
+	return p
+}
+
+// plcaNodes attaches nodes with IDs 0..count-1, using PLCA.
+func (s *segment) plcaNodes(count uint8, burstCount uint8) {
+	for id := uint8(0); id < count; id++ {
+		s.node(id, &t1s.PLCAConf{NodeID: id, NodeCount: count, BurstCount: burstCount, BurstTimer: 128})
+	}
+}
+
+func (s *segment) send(nodes ...byte) {
+	for _, n := range nodes {
+		s.nodes[n].Send(frame(n))
+	}
+}
+
+func bits(n int) time.Duration {
+	return time.Duration(n) * bussim.BitTime
+}
+
+func TestPLCACycle(t *testing.T) {
+	s := newSegment()
+	s.plcaNodes(4, 0)
+	s.bus.Advance(time.Millisecond)
+
+	// An idle cycle consists of a beacon, and a TO timeout
+	// of 32 bit times for each node.
+	cycleBits := 20 + 4*32
+	want := uint64((10000 + cycleBits - 1) / cycleBits)
+	if n := s.bus.Stats().Cycles; n != want {
+		t.Errorf("%d cycles, want %d", n, want)
+	}
+	for id, p := range s.nodes {
+		if !p.PLCAStatus() {
+			t.Errorf("node %d: PLCA not active", id)
+		}
+	}
+}
+
+func TestPLCAOrder(t *testing.T) {
+	s := newSegment()
+	s.plcaNodes(4, 0)
+	s.send(3, 1, 2, 1)
+	s.bus.Advance(time.Millisecond)
+	if want := []byte{1, 2, 3, 1}; !slices.Equal(s.srcs, want) {
+		t.Errorf("senders %v, want %v", s.srcs, want)
+	}
+	if n := s.bus.Stats().Collisions; n != 0 {
+		t.Errorf("%d collisions", n)
+	}
+}
+
+func TestTOTimeout(t *testing.T) {
+	s := newSegment()
+	s.plcaNodes(4, 0)
+	s.send(3)
+	s.bus.Advance(time.Millisecond)
+
+	// Beacon, timeouts of nodes 0..2, and the frame.
+	want := bits(20 + 3*32 + frameBits + ifgBits)
+	if len(s.times) != 1 || s.times[0] != want {
+		t.Errorf("frame received at %v, want %v", s.times, want)
+	}
+}
+
+func TestNoCoordinator(t *testing.T) {
+	s := newSegment()
+	s.node(1, &t1s.PLCAConf{NodeID: 1, NodeCount: 4})
+	s.send(1)
+	s.bus.Advance(time.Millisecond)
+	if s.nodes[1].PLCAStatus() {
+		t.Error("PLCA active without coordinator")
+	}
+	if len(s.srcs) != 1 {
+		t.Errorf("%d frames received, want 1", len(s.srcs))
+	}
+}
+
+func TestBurst(t *testing.T) {
+	for _, tc := range []struct {
+		burstCount uint8
+		want       []byte
+	}{
+		{0, []byte{1, 2, 1, 1, 1}},
+		{2, []byte{1, 1, 1, 2, 1}},
+	} {
+		s := newSegment()
+		s.plcaNodes(3, tc.burstCount)
+		s.send(1, 1, 1, 1, 2)
+		s.bus.Advance(time.Millisecond)
+		if !slices.Equal(s.srcs, tc.want) {
+			t.Errorf("burst count %d: senders %v, want %v", tc.burstCount, s.srcs, tc.want)
+		}
+	}
+}
+
+func TestBurstTimer(t *testing.T) {
+	// Node 1 sends a frame in its TO at 52 bit times; its burst
+	// timer of 128 bit times expires 128 bit times after the end
+	// of the frame. A frame queued until then is sent in the burst;
+	// otherwise, node 2 gets its TO first.
+	frameEnd := 20 + 32 + frameBits
+	for _, tc := range []struct {
+		name  string
+		delay int // bit times after the end of the first frame
+		want  []byte
+	}{
+		{"ready", ifgBits, []byte{1, 1, 2}},
+		{"within", 120, []byte{1, 1, 2}},
+		{"late", 140, []byte{1, 2, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSegment()
+			s.plcaNodes(3, 1)
+			s.send(1, 2)
+			s.bus.Advance(bits(frameEnd + tc.delay))
+			s.send(1)
+			s.bus.Advance(time.Millisecond)
+			if !slices.Equal(s.srcs, tc.want) {
+				t.Errorf("senders %v, want %v", s.srcs, tc.want)
+			}
+		})
+	}
+}
+
+func TestCSMACollision(t *testing.T) {
+	s := newSegment()
+	s.node(1, nil)
+	s.node(2, nil)
+	s.send(1, 2, 1, 2)
+	s.bus.Advance(10 * time.Millisecond)
+
+	st := s.bus.Stats()
+	if st.Collisions == 0 {
+		t.Error("no collisions")
+	}
+	if len(s.srcs) != 4 {
+		t.Errorf("%d frames received, want 4", len(s.srcs))
+	}
+	for n, p := range s.nodes {
+		ps := p.Stats()
+		if ps.Collisions == 0 || ps.Lost != 0 || ps.TxFrames != 2 {
+			t.Errorf("node %d: %+v", n, ps)
+		}
+	}
+}
+
+func TestCSMAOnPLCASegment(t *testing.T) {
+	// A node using CSMA/CD on a PLCA segment collides with the
+	// node owning the TO; the latter, having collision detection
+	// disabled, loses its frame.
+	s := newSegment()
+	s.plcaNodes(2, 0)
+	s.node(5, nil)
+	s.send(0, 5)
+	s.bus.Advance(10 * time.Millisecond)
+	if s.bus.Stats().Collisions == 0 {
+		t.Error("no collisions")
+	}
+	if st := s.nodes[0].Stats(); st.Lost != 1 {
+		t.Errorf("node 0: %+v; want a lost frame", st)
+	}
+	if !slices.Equal(s.srcs, []byte{5}) {
+		t.Errorf("senders %v, want [5]", s.srcs)
+	}
+}
@@ -0,0 +1,53 @@
+package bussim
+
+import (
+	"github.com/knieriem/t1s"
+)
+
+// AttachUpper connects a station to the bus that is represented
+// by an upper protocol layer directly, without an emulated MAC-PHY.
+// Frames are received if they are sent to mac.Addr, to the broadcast
+// address, or to a multicast address, or, if mac.CopyAllFrames is set,
+// regardless of the destination address. The upper layer gets a copy
+// of each frame, which it may modify in place. It is polled for
+// frames to be transmitted while the port's queue is not full.
+// If plca is nil, CSMA/CD is used.
+func (b *Bus) AttachUpper(name string, mac *t1s.MACConf, plca *t1s.PLCAConf, up t1s.UpperProto) *Port {
+	p := b.Attach(name, nil)
+	p.Configure(plca)
+	b.mu.Lock()
+	p.upper = up
+	p.filter = mac
+	p.pollBuf = make([]byte, 1536)
+	p.recv = p.recvUpper
+	b.mu.Unlock()
+	return p
+}
+
+func (p *Port) recvUpper(frame []byte) {
+	if len(frame) < t1s.AddrLen {
+		return
+	}
+	da := t1s.Frame(frame).Dst()
+	if !p.filter.CopyAllFrames && !t1s.IsMulticast(da) && da != p.filter.Addr {
+		return
+	}
+	// The upper layer may modify the frame, like a VLAN multiplexer
+	// removing a tag in place, so it gets a copy of its own.
+	p.rxBuf = append(p.rxBuf[:0], frame...)
+	p.upper.SendEthUp(p.rxBuf)
+}
+
+// pollUpper is called with the bus lock held.
+func (p *Port) pollUpper() {
+	b := p.bus
+	for len(p.queue) < p.QueueLen {
+		b.mu.Unlock()
+		n, err := p.upper.PollForEth(p.pollBuf)
+		b.mu.Lock()
+		if err != nil || n == 0 {
+			return
+		}
+		p.enqueue(p.pollBuf[:n])
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/knieriem/t1s/cmd
+
+go 1.22.2
+
+require (
+	github.com/knieriem/t1s v0.0.0-20240506205313-189e7e6390fe
+	github.com/knieriem/t1s/lan865x/periphdev v0.0.0-00010101000000-000000000000
+)
+
+require (
+	periph.io/x/conn/v3 v3.7.0 // indirect
+	periph.io/x/host/v3 v3.8.2 // indirect
+)
+
+replace (
+	github.com/knieriem/t1s => ../
+	github.com/knieriem/t1s/lan865x/periphdev => ../lan865x/periphdev
+)
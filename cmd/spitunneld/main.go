@@ -0,0 +1,59 @@
+// Command spitunneld exposes a LAN865x attached to a Linux host via spidev
+// to clients on the network, using package spitunnel. A driver running
+// on another machine, like t1sctl with flag -remote, may then access
+// the MAC-PHY as if it were attached locally.
+//
+// Usage:
+//
+//	spitunneld [flags]
+//
+// Only one client at a time is served. Since clients are not
+// authenticated, spitunneld listens at localhost by default; to
+// access it from another host, use an SSH tunnel, or, on a trusted
+// network, set flag -l to an address like ":7865".
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"github.com/knieriem/t1s/lan865x/periphdev"
+	"github.com/knieriem/t1s/lan865x/tc6/spitunnel"
+)
+
+var (
+	hwConf = periphdev.DefaultConf
+
+	listenAddr = flag.String("l", "localhost:7865", "listen at TCP `address`")
+)
+
+func main() {
+	hwConf.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	dev, err := periphdev.Open(&hwConf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+
+	l, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("listening at", l.Addr())
+	srv := &spitunnel.Server{Dev: dev.HwIntf(nil)}
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			log.Println("client connected:", c.RemoteAddr())
+			err := srv.ServeConn(c)
+			c.Close()
+			log.Println("client disconnected:", c.RemoteAddr(), err)
+		}()
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/discover"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/tc6"
+	"github.com/knieriem/t1s/lldp"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+func cmdInfo(t *tool, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	c := t.inst.ChipInfo()
+	fmt.Printf("chip:\t%s\n", c)
+	fmt.Printf("phy id:\t0x%08x\n", c.PHYID)
+	fmt.Printf("oa tc6:\t%s\n", c.TC6VersionString())
+	bot, err := t.inst.ReadReg(tc6.RegSpecAddr2Bot)
+	if err != nil {
+		return err
+	}
+	top, err := t.inst.ReadReg(tc6.RegSpecAddr2Top)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("mac:\t% x\n", []byte{byte(bot), byte(bot >> 8), byte(bot >> 16), byte(bot >> 24), byte(top), byte(top >> 8)})
+	return printPLCA(t)
+}
+
+// Bits of PLCA_CTRL0, PLCA_STS, and values of COL_DET_CTRL0.
+const (
+	plcaEnable     = 1 << 15
+	plcaActive     = 1 << 15
+	colDetEnabled  = 0x8083
+	colDetDisabled = 0x0083
+)
+
+// readPLCA reads the PLCA configuration from the MAC-PHY.
+func readPLCA(t *tool) (enabled bool, c t1s.PLCAConf, err error) {
+	var v [3]uint32
+	for i, addr := range []uint32{tc6.RegPLCACtrl0, tc6.RegPLCACtrl1, tc6.RegPLCABurst} {
+		v[i], err = t.inst.ReadReg(addr)
+		if err != nil {
+			return false, c, err
+		}
+	}
+	c.NodeID = uint8(v[1])
+	c.NodeCount = uint8(v[1] >> 8)
+	c.BurstCount = uint8(v[2] >> 8)
+	c.BurstTimer = uint8(v[2])
+	return v[0]&plcaEnable != 0, c, nil
+}
+
+func printPLCA(t *tool) error {
+	enabled, c, err := readPLCA(t)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		fmt.Printf("plca:\tdisabled\n")
+		return nil
+	}
+	sts, err := t.inst.ReadReg(tc6.RegPLCAStatus)
+	if err != nil {
+		return err
+	}
+	status := "no beacons"
+	if sts&plcaActive != 0 {
+		status = "active"
+	}
+	fmt.Printf("plca:\tid %d, count %d, burst %d, burst timer %d, %s\n",
+		c.NodeID, c.NodeCount, c.BurstCount, c.BurstTimer, status)
+	return nil
+}
+
+func cmdRegs(t *tool, args []string) error {
+	if len(args) == 0 || args[0] != "dump" || len(args) > 2 {
+		return errUsage
+	}
+	mms := -1
+	if len(args) == 2 {
+		m, err := strconv.ParseUint(args[1], 0, 4)
+		if err != nil {
+			return err
+		}
+		mms = int(m)
+	}
+	for _, addr := range tc6.RegAddrs() {
+		if mms != -1 && int(addr>>16) != mms {
+			continue
+		}
+		v, err := t.inst.ReadReg(addr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tc6.FormatAddr(addr), err)
+		}
+		fmt.Printf("%-28s 0x%08x\n", tc6.FormatAddr(addr), v)
+	}
+	return nil
+}
+
+func cmdReg(t *tool, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	addr, err := tc6.ParseAddr(args[1])
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "read" && len(args) == 2:
+		v, err := t.inst.ReadReg(addr)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s 0x%08x\n", tc6.FormatAddr(addr), v)
+	case args[0] == "write" && len(args) == 3:
+		v, err := strconv.ParseUint(args[2], 0, 32)
+		if err != nil {
+			return err
+		}
+		return t.inst.WriteReg(addr, uint32(v))
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+// plcaFields maps the flags of command plca set
+// to the fields of the PLCA configuration.
+var plcaFields = []struct {
+	name, usage string
+	field       func(c *t1s.PLCAConf) *uint8
+}{
+	{"id", "PLCA node `id`", func(c *t1s.PLCAConf) *uint8 { return &c.NodeID }},
+	{"count", "PLCA node `count`", func(c *t1s.PLCAConf) *uint8 { return &c.NodeCount }},
+	{"burst", "PLCA burst `count`", func(c *t1s.PLCAConf) *uint8 { return &c.BurstCount }},
+	{"burst-timer", "PLCA burst `timer`, in bit times", func(c *t1s.PLCAConf) *uint8 { return &c.BurstTimer }},
+}
+
+var plcaSet struct {
+	vals    map[string]uint8 // values of the flags set
+	disable bool
+}
+
+func plcaSetFlags(fs *flag.FlagSet) {
+	plcaSet.vals = make(map[string]uint8)
+	for _, f := range plcaFields {
+		name := f.name
+		fs.Func(name, f.usage, func(s string) error {
+			var v uint8
+			if err := uint8Setter(&v)(s); err != nil {
+				return err
+			}
+			plcaSet.vals[name] = v
+			return nil
+		})
+	}
+	fs.BoolVar(&plcaSet.disable, "off", false, "disable PLCA")
+}
+
+// cmdPLCA changes the PLCA configuration of a running node by writing
+// the registers directly, like the oa-tc6 library does when initializing
+// the MAC-PHY. Settings not given by flags are kept.
+func cmdPLCA(t *tool, args []string) error {
+	if len(args) != 1 || args[0] != "set" {
+		return errUsage
+	}
+	var regs [][2]uint32
+	if plcaSet.disable {
+		regs = [][2]uint32{
+			{tc6.RegPLCACtrl0, 0},
+			{tc6.RegColDetCtrl0, colDetEnabled},
+		}
+	} else {
+		_, c, err := readPLCA(t)
+		if err != nil {
+			return err
+		}
+		for _, f := range plcaFields {
+			if v, ok := plcaSet.vals[f.name]; ok {
+				*f.field(&c) = v
+			}
+		}
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		regs = [][2]uint32{
+			{tc6.RegColDetCtrl0, colDetDisabled},
+			{tc6.RegPLCACtrl1, uint32(c.NodeCount)<<8 | uint32(c.NodeID)},
+			{tc6.RegPLCABurst, uint32(c.BurstCount)<<8 | uint32(c.BurstTimer)},
+			{tc6.RegPLCACtrl0, plcaEnable},
+		}
+	}
+	for _, r := range regs {
+		if err := t.inst.WriteReg(r[0], r[1]); err != nil {
+			return fmt.Errorf("%s: %w", tc6.RegName(r[0]), err)
+		}
+	}
+	// Wait for beacons.
+	time.Sleep(100 * time.Millisecond)
+	return printPLCA(t)
+}
+
+var runDuration time.Duration
+
+func statsFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&runDuration, "t", time.Second, "duration to run the driver, 0 means until interrupted")
+}
+
+func cmdStats(t *tool, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	t.service(runDuration, nil)
+	if err := t.inst.UpdateStatus(); err != nil {
+		return err
+	}
+	s := t.inst.Stats()
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	for _, c := range []struct {
+		name string
+		n    uint64
+	}{
+		{"rx frames", s.RxFrames},
+		{"rx bytes", s.RxBytes},
+		{"rx errors", s.RxErrors},
+		{"rx filtered", s.RxFiltered},
+		{"rx upper errors", s.RxUpperErrors},
+		{"rx overflows", s.RxOverflows},
+		{"tx frames", s.TxFrames},
+		{"tx bytes", s.TxBytes},
+		{"tx errors", s.TxErrors},
+		{"tx overflows", s.TxOverflows},
+		{"reinits", s.Reinits},
+		{"wakeups", s.Wakeups},
+	} {
+		fmt.Fprintf(w, "%s:\t%d\n", c.name, c.n)
+	}
+	for i, n := range s.Events {
+		if n != 0 {
+			fmt.Fprintf(w, "event %s:\t%d\n", lan865x.Event(i), n)
+		}
+	}
+	for i, n := range s.ProtoErrors {
+		if n != 0 {
+			fmt.Fprintf(w, "error %q:\t%d\n", lan865x.ProtoError(i).Error(), n)
+		}
+	}
+	fmt.Fprintf(w, "link up:\t%v\n", s.LinkUp)
+	for _, addr := range []uint32{tc6.RegStatus0, tc6.RegStatus1, tc6.RegBufSts} {
+		v, err := t.inst.ReadReg(addr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s:\t0x%08x\n", tc6.RegName(addr), v)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return printPLCA(t)
+}
+
+var follow bool
+
+func eventsFlags(fs *flag.FlagSet) {
+	statsFlags(fs)
+	fs.BoolVar(&follow, "follow", false, "print events until interrupted")
+}
+
+func cmdEvents(t *tool, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	u := &t.up
+	t0 := time.Now()
+	show := func(ev string) {
+		fmt.Printf("%10.3f %s\n", time.Since(t0).Seconds(), ev)
+	}
+	u.eventFunc = func(ev lan865x.Event) {
+		s := ev.String()
+		if ev.NeedsReinit() {
+			s += " (reinit)"
+		}
+		show(s)
+	}
+	t.inst.OnProtoError = func(err lan865x.ProtoError) {
+		show(err.Error())
+	}
+	d := runDuration
+	if follow {
+		d = 0
+	}
+	t.service(d, nil)
+	return nil
+}
+
+var captureFile string
+
+func captureFlags(fs *flag.FlagSet) {
+	statsFlags(fs)
+	fs.StringVar(&captureFile, "w", "", "write frames to pcapng `file`")
+	fs.IntVar(&captureCount, "c", 0, "stop after `n` frames")
+}
+
+var captureCount int
+
+func cmdCapture(t *tool, args []string) error {
+	if len(args) != 0 || captureFile == "" {
+		return errUsage
+	}
+	f, err := os.Create(captureFile)
+	if err != nil {
+		return err
+	}
+	w, err := newPcapngWriter(f, "t1s0", fcsLen)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	u := &t.up
+	u.rxFunc = func(frame []byte) {
+		if err == nil {
+			err = w.writeFrame(time.Now(), frame)
+		}
+	}
+	t.service(runDuration, func() bool {
+		return err == nil && (captureCount == 0 || u.rxFrames < captureCount)
+	})
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	fmt.Fprintf(os.Stderr, "%d frames captured\n", u.rxFrames)
+	return err
+}
+
+// fcsLen is the length of the frame check sequence
+// included in frames received from the LAN865x.
+const fcsLen = 4
+
+// upper is the upper protocol layer of the tool; it
+// counts received frames, and does not transmit.
+type upper struct {
+	rxFrames  int
+	rxFunc    func(frame []byte)
+	eventFunc func(ev lan865x.Event)
+}
+
+func (u *upper) SendEthUp(frame []byte) error {
+	u.rxFrames++
+	if u.rxFunc != nil {
+		u.rxFunc(frame)
+	}
+	return nil
+}
+
+func (u *upper) PollForEth(buf []byte) (int, error) {
+	return 0, nil
+}
+
+func (u *upper) onEvent(ev lan865x.Event) {
+	if u.eventFunc != nil {
+		u.eventFunc(ev)
+	}
+}
+
+var neighborsConf struct {
+	d        time.Duration
+	interval time.Duration
+	name     string
+}
+
+func neighborsFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&neighborsConf.d, "t", 35*time.Second, "duration to listen for advertisements")
+	fs.DurationVar(&neighborsConf.interval, "interval", lldp.DefaultInterval, "LLDP transmit `interval`")
+	fs.StringVar(&neighborsConf.name, "name", "", "system `name` to advertise; defaults to the host name")
+}
+
+func cmdNeighbors(t *tool, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	name := neighborsConf.name
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	a := &lldp.Agent{
+		MAC:        t.inst.MAC,
+		PLCA:       t.inst.PLCA,
+		SystemName: name,
+		Interval:   neighborsConf.interval,
+		Ticks:      t.inst.Ticks,
+		Next:       &t.up,
+	}
+	if err := t.inst.AddMulticast(lldp.Addr); err != nil {
+		return err
+	}
+	t.inst.UpperProto = a
+	t.service(neighborsConf.d, nil)
+
+	// Let neighbors remove this node from their tables.
+	a.Shutdown()
+	t.service(10*time.Millisecond, nil)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDR\tPLCA\tNAME\tMGMT ADDR\tTTL")
+	for _, nb := range a.Neighbors() {
+		p := "-"
+		if nb.PLCA != nil {
+			p = fmt.Sprintf("%d/%d", nb.PLCA.NodeID, nb.PLCA.NodeCount)
+		} else if nb.PLCAAdvertised {
+			p = "off"
+		}
+		mgmt := "-"
+		if nb.MgmtAddr.IsValid() {
+			mgmt = nb.MgmtAddr.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", net.HardwareAddr(nb.Addr[:]), p, nb.SystemName, mgmt, nb.TTL)
+	}
+	return w.Flush()
+}
+
+var discoverWait time.Duration
+
+func discoverFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&discoverWait, "t", 500*time.Millisecond, "duration to wait for replies")
+}
+
+func cmdDiscover(t *tool, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	name, _ := os.Hostname()
+	a := &discover.Agent{
+		MAC:          t.inst.MAC,
+		PLCA:         t.inst.PLCA,
+		Name:         name,
+		ChipRevision: t.inst.ChipInfo().String(),
+		Ticks:        t.inst.Ticks,
+		Next:         &t.up,
+	}
+	t.inst.UpperProto = a
+	a.Probe()
+	t.service(discoverWait, nil)
+
+	r := discover.Analyze(append(a.Replies(), a.Self()))
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDR\tPLCA\tBURST\tNAME\tCHIP\tFIRMWARE")
+	for _, n := range r.Nodes {
+		p, burst := "off", "-"
+		if n.PLCA != nil {
+			p = fmt.Sprintf("%d/%d", n.PLCA.NodeID, n.PLCA.NodeCount)
+			burst = fmt.Sprintf("%d/%d", n.PLCA.BurstCount, n.PLCA.BurstTimer)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", net.HardwareAddr(n.Addr[:]), p, burst, dash(n.Name), dash(n.ChipRevision), dash(n.Firmware))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, c := range r.Conflicts {
+		fmt.Println("conflict:", c)
+	}
+	if len(r.Gaps) != 0 {
+		fmt.Println("unused node IDs:", r.Gaps)
+	}
+	return nil
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+var sleepConf struct {
+	lan865x.SleepConf
+	d time.Duration
+}
+
+func sleepFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&sleepConf.d, "t", 10*time.Second, "duration after which the MAC-PHY is woken up, unless it wakes up earlier")
+	fs.BoolVar(&sleepConf.WakeOnBus, "bus", true, "enable wake-up by the bus")
+	fs.BoolVar(&sleepConf.WakeOnPin, "pin", true, "enable wake-up by the WAKE_IN pin")
+	fs.BoolVar(&sleepConf.ForwardWake, "fwd", false, "forward wake-ups at WAKE_IN to the bus")
+}
+
+func cmdSleep(t *tool, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	var src lan865x.WakeSource
+	woke := false
+	t.inst.OnWake = func(s lan865x.WakeSource) {
+		src, woke = s, true
+	}
+	if err := t.inst.Sleep(&sleepConf.SleepConf); err != nil {
+		return err
+	}
+	start := time.Now()
+	t.service(sleepConf.d, func() bool {
+		return t.inst.PowerState() == lan865x.PowerSleep
+	})
+	if t.inst.PowerState() == lan865x.PowerSleep {
+		if err := t.inst.Wake(); err != nil {
+			return err
+		}
+	}
+	t.service(2*time.Second, func() bool {
+		return !woke && t.inst.PowerState() != lan865x.PowerWakeFailed
+	})
+	if !woke {
+		return fmt.Errorf("MAC-PHY did not wake up (power state: %v)", t.inst.PowerState())
+	}
+	fmt.Printf("woken up by %s after %v\n", src, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,303 @@
+// Command t1sctl inspects and configures a LAN865x MAC-PHY
+// attached to a Linux host via spidev.
+//
+// Usage:
+//
+//	t1sctl [flags] command [args]
+//
+// The commands are:
+//
+//	info                          print chip, MAC and PLCA information
+//	regs dump [mms]               print all known registers
+//	reg read addr                 read a register
+//	reg write addr value          write a register
+//	plca set [flags]              change the PLCA configuration of a running node
+//	stats                         run the driver, and print counters
+//	events [-follow]              run the driver, and print events
+//	capture -w file.pcapng        capture received frames
+//	neighbors [-t duration]       list the nodes advertised via LLDP
+//	discover [-t duration]        probe all nodes, and check their PLCA settings
+//	sleep [-t duration]           put the MAC-PHY to sleep until woken up
+//
+// Register addresses may be specified by name, like MAC_NCFGR,
+// as mms:addr, like 1:0x0001, or as a number containing the
+// memory map selector in bits 16..19, like 0x10001.
+//
+// Commands info, regs, reg and plca only access registers of a MAC-PHY
+// that has already been initialized, without resetting it, so that
+// a running node may be inspected, and its PLCA settings changed.
+// All other commands, and these if flag -init is
+// set, reset and initialize the MAC-PHY first, using the MAC address
+// and PLCA settings given by the flags, which must be set explicitly:
+// -mac, and either -plca-id or -csmacd.
+//
+// Using flag -remote, a MAC-PHY exposed by command spitunneld on another
+// host may be accessed instead of a local one.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/periphdev"
+	"github.com/knieriem/t1s/lan865x/slogger"
+	"github.com/knieriem/t1s/lan865x/tc6/spitrace"
+	"github.com/knieriem/t1s/lan865x/tc6/spitunnel"
+)
+
+var (
+	hwConf = periphdev.DefaultConf
+
+	macAddr  = flag.String("mac", "", "MAC `address`")
+	csmacd   = flag.Bool("csmacd", false, "use CSMA/CD, disable PLCA")
+	promisc  = flag.Bool("promisc", false, "receive all frames")
+	remote   = flag.String("remote", "", "access the MAC-PHY via a spitunneld server at `addr`")
+	initAll  = flag.Bool("init", false, "initialize the MAC-PHY also for commands that only access registers")
+	verbose  = flag.Bool("v", false, "log driver messages")
+	svcPause = flag.Duration("svc-pause", time.Millisecond, "pause between driver service calls")
+
+	plca = t1s.PLCAConf{
+		NodeCount:  8,
+		BurstTimer: 128,
+	}
+)
+
+func init() {
+	hwConf.RegisterFlags(flag.CommandLine)
+	registerPLCAFlags(flag.CommandLine, &plca)
+}
+
+func registerPLCAFlags(fs *flag.FlagSet, c *t1s.PLCAConf) {
+	fs.Func("plca-id", "PLCA node `id`", uint8Setter(&c.NodeID))
+	fs.Func("plca-count", "PLCA node `count`", uint8Setter(&c.NodeCount))
+	fs.Func("plca-burst", "PLCA burst `count`", uint8Setter(&c.BurstCount))
+	fs.Func("plca-burst-timer", "PLCA burst `timer`, in bit times", uint8Setter(&c.BurstTimer))
+}
+
+type command struct {
+	name  string
+	args  string
+	run   func(t *tool, args []string) error
+	flags func(fs *flag.FlagSet)
+
+	// verb means that the flags follow a verb, like "set".
+	verb bool
+
+	// regsOnly means that the command only accesses registers,
+	// so that the MAC-PHY need not be initialized.
+	regsOnly bool
+}
+
+var commands = []*command{
+	{name: "info", run: cmdInfo, regsOnly: true},
+	{name: "regs", args: "dump [mms]", run: cmdRegs, regsOnly: true},
+	{name: "reg", args: "read addr | write addr value", run: cmdReg, regsOnly: true},
+	{name: "plca", args: "set [flags]", run: cmdPLCA, flags: plcaSetFlags, verb: true, regsOnly: true},
+	{name: "stats", args: "[flags]", run: cmdStats, flags: statsFlags},
+	{name: "events", args: "[flags]", run: cmdEvents, flags: eventsFlags},
+	{name: "capture", args: "-w file [flags]", run: cmdCapture, flags: captureFlags},
+	{name: "neighbors", args: "[flags]", run: cmdNeighbors, flags: neighborsFlags},
+	{name: "discover", args: "[flags]", run: cmdDiscover, flags: discoverFlags},
+	{name: "sleep", args: "[flags]", run: cmdSleep, flags: sleepFlags},
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "usage: t1sctl [flags] command [args]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t%s %s\n", c.name, c.args)
+	}
+	fmt.Fprintf(w, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "t1sctl: unknown command: %s\n", name)
+		usage()
+		os.Exit(2)
+	}
+	args := flag.Args()[1:]
+	if cmd.flags != nil {
+		var verb []string
+		if cmd.verb && len(args) != 0 {
+			verb, args = args[:1], args[1:]
+		}
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		cmd.flags(fs)
+		fs.Parse(args)
+		args = append(verb, fs.Args()...)
+	}
+	err := run(cmd, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "t1sctl:", err)
+		os.Exit(1)
+	}
+}
+
+// tool contains the state shared by all commands.
+type tool struct {
+	inst lan865x.Inst
+	up   upper
+	log  *slog.Logger
+}
+
+func run(cmd *command, args []string) error {
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	if hwConf.TraceSPI {
+		level = spitrace.LevelTrace
+	}
+	t := new(tool)
+	t.log = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	hwi, closeDev, err := openDev(t.log.WithGroup("spi"))
+	if err != nil {
+		return err
+	}
+	defer closeDev()
+	if cmd.regsOnly && !*initAll {
+		t.inst = lan865x.Inst{
+			Dev:   hwi,
+			Ticks: periphdev.NewTicks(),
+			Log:   slogger.New(t.log.Handler()),
+		}
+		if err := t.inst.Attach(); err != nil {
+			return fmt.Errorf("%w (MAC-PHY not initialized? see flag -init)", err)
+		}
+		return cmd.run(t, args)
+	}
+	mac, err := initConf()
+	if err != nil {
+		return err
+	}
+	t.inst = lan865x.Inst{
+		MAC: &t1s.MACConf{
+			Addr:          mac,
+			CopyAllFrames: *promisc,
+		},
+		PLCA:       &plca,
+		UpperProto: &t.up,
+		Dev:        hwi,
+		Ticks:      periphdev.NewTicks(),
+		OnEvent:    t.up.onEvent,
+		Log:        slogger.New(t.log.Handler()),
+	}
+	if *csmacd {
+		t.inst.PLCA = nil
+	}
+	if err := t.inst.Init(); err != nil {
+		return err
+	}
+	return cmd.run(t, args)
+}
+
+// initConf checks the flags needed to initialize the MAC-PHY. To avoid
+// disturbing a segment, like by a second PLCA coordinator, these
+// must be set explicitly, instead of falling back to defaults.
+func initConf() (mac [6]byte, err error) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if !set["mac"] {
+		return mac, errors.New("flag -mac must be set to initialize the MAC-PHY")
+	}
+	if !set["plca-id"] && !*csmacd {
+		return mac, errors.New("flag -plca-id, or -csmacd, must be set to initialize the MAC-PHY")
+	}
+	a, err := net.ParseMAC(*macAddr)
+	if err != nil {
+		return mac, err
+	}
+	if len(a) != 6 {
+		return mac, errors.New("invalid MAC address: " + *macAddr)
+	}
+	if !*csmacd {
+		if err := plca.Validate(); err != nil {
+			return mac, err
+		}
+	}
+	return [6]byte(a), nil
+}
+
+// openDev opens the local MAC-PHY, or connects
+// to a remote one, if flag -remote is set.
+func openDev(traceLog *slog.Logger) (hwi lan865x.HwIntf, closeDev func() error, err error) {
+	if *remote == "" {
+		dev, err := periphdev.Open(&hwConf)
+		if err != nil {
+			return nil, nil, err
+		}
+		return dev.HwIntf(traceLog), dev.Close, nil
+	}
+	c, err := spitunnel.Dial(*remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	hwi = c
+	if hwConf.TraceSPI {
+		hwi = &spitrace.Intf{HwIntf: c, Log: traceLog}
+	}
+	return hwi, c.Close, nil
+}
+
+// service runs the driver for duration d, or, if d is zero, until
+// the program is interrupted. The function f, if not nil, is called
+// after each service call, and may stop the loop by returning false.
+func (t *tool) service(d time.Duration, f func() bool) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if d != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	for ctx.Err() == nil {
+		for {
+			done := t.inst.Service()
+			if done {
+				break
+			}
+		}
+		if f != nil && !f() {
+			return
+		}
+		time.Sleep(*svcPause)
+	}
+}
+
+func uint8Setter(p *uint8) func(string) error {
+	return func(s string) error {
+		v, err := strconv.ParseUint(s, 0, 8)
+		if err != nil {
+			return err
+		}
+		*p = uint8(v)
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// Block types and options of the pcapng format,
+// see https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-02.html
+const (
+	blockSHB = 0x0A0D0D0A
+	blockIDB = 0x00000001
+	blockEPB = 0x00000006
+
+	byteOrderMagic = 0x1A2B3C4D
+
+	linkTypeEthernet = 1
+
+	optEnd      = 0
+	optIfName   = 2
+	optIfTsRes  = 9
+	optIfFCSLen = 13
+)
+
+// pcapngWriter writes frames received on a single
+// interface to a pcapng file, with microsecond timestamps.
+type pcapngWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func newPcapngWriter(w io.Writer, ifName string, fcsLen int) (*pcapngWriter, error) {
+	pw := &pcapngWriter{w: w}
+
+	b := pw.begin(blockSHB)
+	b = le.AppendUint32(b, byteOrderMagic)
+	b = le.AppendUint16(b, 1) // major version
+	b = le.AppendUint16(b, 0) // minor version
+	b = le.AppendUint64(b, ^uint64(0))
+	err := pw.end(b)
+	if err != nil {
+		return nil, err
+	}
+
+	b = pw.begin(blockIDB)
+	b = le.AppendUint16(b, linkTypeEthernet)
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint32(b, 0) // snap length
+	b = appendOption(b, optIfName, []byte(ifName))
+	b = appendOption(b, optIfTsRes, []byte{6})
+	b = appendOption(b, optIfFCSLen, []byte{byte(fcsLen)})
+	b = appendOption(b, optEnd, nil)
+	return pw, pw.end(b)
+}
+
+func (pw *pcapngWriter) writeFrame(t time.Time, frame []byte) error {
+	ts := uint64(t.UnixMicro())
+	b := pw.begin(blockEPB)
+	b = le.AppendUint32(b, 0) // interface ID
+	b = le.AppendUint32(b, uint32(ts>>32))
+	b = le.AppendUint32(b, uint32(ts))
+	b = le.AppendUint32(b, uint32(len(frame)))
+	b = le.AppendUint32(b, uint32(len(frame)))
+	b = append(b, frame...)
+	b = pad(b)
+	return pw.end(b)
+}
+
+var le = binary.LittleEndian
+
+func (pw *pcapngWriter) begin(blockType uint32) []byte {
+	b := pw.buf[:0]
+	b = le.AppendUint32(b, blockType)
+	return le.AppendUint32(b, 0) // length, set by end
+}
+
+func (pw *pcapngWriter) end(b []byte) error {
+	n := uint32(len(b) + 4)
+	le.PutUint32(b[4:], n)
+	b = le.AppendUint32(b, n)
+	pw.buf = b
+	_, err := pw.w.Write(b)
+	return err
+}
+
+func appendOption(b []byte, code uint16, val []byte) []byte {
+	b = le.AppendUint16(b, code)
+	b = le.AppendUint16(b, uint16(len(val)))
+	b = append(b, val...)
+	return pad(b)
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
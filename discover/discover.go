@@ -0,0 +1,349 @@
+// Package discover implements a segment discovery protocol on top of
+// [t1s.UpperProto]. A prober broadcasts a probe, to which the agents of
+// all nodes reply with their MAC address, PLCA settings, chip revision,
+// and firmware version. The replies may be checked for a consistent
+// PLCA configuration using Analyze.
+package discover
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/knieriem/t1s"
+)
+
+// EtherType is the EtherType of probes and replies.
+const EtherType = t1s.EtherTypeLocalExp2
+
+const (
+	version = 1
+
+	opProbe = 1
+	opReply = 2
+
+	// magic, version, op, token
+	msgHeaderLen = 4 + 1 + 1 + 4
+
+	// flags, node ID, node count, burst count, burst timer
+	plcaInfoLen = 5
+
+	plcaEnabled = 1 << 0
+
+	maxStrLen = 255
+)
+
+var magic = [4]byte{'T', '1', 'S', 'D'}
+
+// DefaultMaxReplyDelay is used if Agent.MaxReplyDelay is zero.
+const DefaultMaxReplyDelay = 50 * time.Millisecond
+
+var ErrMalformed = errors.New("discover: malformed message")
+
+// Reply describes a node that replied to a probe.
+type Reply struct {
+	Addr [t1s.AddrLen]byte
+
+	// PLCA contains the node's settings; nil means CSMA/CD.
+	PLCA *t1s.PLCAConf
+
+	Name         string
+	ChipRevision string
+	Firmware     string
+}
+
+// Label returns a name identifying the node in reports:
+// its MAC address, preceded by its name, if set.
+func (r *Reply) Label() string {
+	const hex = "0123456789abcdef"
+	b := make([]byte, 0, len(r.Name)+1+3*t1s.AddrLen)
+	if r.Name != "" {
+		b = append(b, r.Name...)
+		b = append(b, ' ')
+	}
+	for i, c := range r.Addr {
+		if i > 0 {
+			b = append(b, ':')
+		}
+		b = append(b, hex[c>>4], hex[c&0xF])
+	}
+	return string(b)
+}
+
+// Agent replies to probes, and, if requested, sends a probe and
+// collects the replies. Frames of other protocols are passed
+// to and polled from the Next upper layer.
+//
+// The settings must not be changed while the agent is in use, except
+// from within the goroutine calling its UpperProto methods.
+type Agent struct {
+	MAC  *t1s.MACConf
+	PLCA *t1s.PLCAConf // nil means CSMA/CD
+
+	Name         string
+	ChipRevision string
+	Firmware     string
+
+	// Replies are delayed randomly, up to MaxReplyDelay, so that the
+	// replies of nodes sharing a node ID, which would collide within
+	// the same transmit opportunity, are likely sent in different cycles.
+	// If Ticks is nil, replies are sent immediately.
+	MaxReplyDelay time.Duration
+	Ticks         t1s.TicksProvider
+
+	Next t1s.UpperProto
+
+	mu      sync.Mutex
+	probe   bool // probe pending
+	token   uint32
+	replies []Reply
+
+	// reply pending
+	replyTo    [t1s.AddrLen]byte
+	replyToken uint32
+	replyAt    uint32
+	reply      bool
+}
+
+// Self returns the reply a describes itself with.
+func (a *Agent) Self() Reply {
+	r := Reply{
+		Addr:         a.MAC.Addr,
+		Name:         a.Name,
+		ChipRevision: a.ChipRevision,
+		Firmware:     a.Firmware,
+	}
+	if a.PLCA != nil {
+		c := *a.PLCA
+		r.PLCA = &c
+	}
+	return r
+}
+
+// Probe makes the agent broadcast a probe at the next poll, and discards
+// the replies collected so far, and any replies to previous probes
+// received later. It may be called from any goroutine.
+func (a *Agent) Probe() {
+	a.mu.Lock()
+	a.probe = true
+	a.token++
+	a.replies = a.replies[:0]
+	a.mu.Unlock()
+}
+
+// Replies returns the replies to the last probe, sorted by
+// PLCA node ID, and by address. Nodes using CSMA/CD
+// are listed last. It may be called from any goroutine.
+func (a *Agent) Replies() []Reply {
+	a.mu.Lock()
+	list := append([]Reply(nil), a.replies...)
+	a.mu.Unlock()
+	sortReplies(list)
+	return list
+}
+
+func sortReplies(list []Reply) {
+	sort.Slice(list, func(i, j int) bool {
+		pi, pj := list[i].PLCA, list[j].PLCA
+		switch {
+		case pi != nil && pj != nil && pi.NodeID != pj.NodeID:
+			return pi.NodeID < pj.NodeID
+		case (pi == nil) != (pj == nil):
+			return pi != nil
+		}
+		return string(list[i].Addr[:]) < string(list[j].Addr[:])
+	})
+}
+
+// SendEthUp processes probes and replies,
+// and passes other frames to a.Next.
+func (a *Agent) SendEthUp(pkt []byte) error {
+	f := t1s.Frame(pkt)
+	if !f.Valid() || f.EtherType() != EtherType {
+		if a.Next == nil {
+			return nil
+		}
+		return a.Next.SendEthUp(pkt)
+	}
+	p := f.Payload()
+	if len(p) < msgHeaderLen || [4]byte(p[:4]) != magic || p[4] != version {
+		return ErrMalformed
+	}
+	token := be32(p[6:])
+	switch p[5] {
+	case opProbe:
+		a.mu.Lock()
+		a.reply = true
+		a.replyTo = f.Src()
+		a.replyToken = token
+		if a.Ticks != nil {
+			a.replyAt = a.Ticks.Milliseconds() + a.replyDelay()
+		}
+		a.mu.Unlock()
+	case opReply:
+		r := Reply{Addr: f.Src()}
+		if err := r.decode(p[msgHeaderLen:]); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if token != a.token {
+			return nil
+		}
+		for i := range a.replies {
+			if a.replies[i].Addr == r.Addr {
+				a.replies[i] = r
+				return nil
+			}
+		}
+		a.replies = append(a.replies, r)
+	default:
+		return ErrMalformed
+	}
+	return nil
+}
+
+// PollForEth returns a pending probe or reply; otherwise a.Next is polled.
+func (a *Agent) PollForEth(buf []byte) (int, error) {
+	a.mu.Lock()
+	var op byte
+	var dst [t1s.AddrLen]byte
+	var token uint32
+	switch {
+	case a.probe:
+		a.probe = false
+		op, dst, token = opProbe, t1s.BroadcastAddr, a.token
+	case a.reply && (a.Ticks == nil || int32(a.Ticks.Milliseconds()-a.replyAt) >= 0):
+		a.reply = false
+		op, dst, token = opReply, a.replyTo, a.replyToken
+	}
+	a.mu.Unlock()
+	if op == 0 {
+		if a.Next == nil {
+			return 0, nil
+		}
+		return a.Next.PollForEth(buf)
+	}
+
+	h := t1s.Header{Dst: dst, Src: a.MAC.Addr, EtherType: EtherType}
+	n, err := h.Put(buf)
+	if err != nil {
+		return 0, err
+	}
+	b := buf[n:]
+	if len(b) < msgHeaderLen {
+		return 0, t1s.ErrShortBuffer
+	}
+	copy(b, magic[:])
+	b[4] = version
+	b[5] = op
+	putBE32(b[6:], token)
+	n += msgHeaderLen
+	if op == opReply {
+		self := a.Self()
+		m, err := self.encode(buf[n:])
+		if err != nil {
+			return 0, err
+		}
+		n += m
+	}
+	return t1s.Pad(buf, n)
+}
+
+// replyDelay returns a random delay in milliseconds.
+func (a *Agent) replyDelay() uint32 {
+	d := a.MaxReplyDelay
+	if d == 0 {
+		d = DefaultMaxReplyDelay
+	}
+	ms := int(d / time.Millisecond)
+	if ms <= 0 {
+		return 0
+	}
+	return uint32(rand.Intn(ms + 1))
+}
+
+func (r *Reply) encode(b []byte) (int, error) {
+	strs := [...]string{r.Name, r.ChipRevision, r.Firmware}
+	n := plcaInfoLen
+	for _, s := range strs {
+		n += 1 + min(len(s), maxStrLen)
+	}
+	if len(b) < n {
+		return 0, t1s.ErrShortBuffer
+	}
+	clear(b[:plcaInfoLen])
+	if c := r.PLCA; c != nil {
+		b[0] = plcaEnabled
+		b[1] = c.NodeID
+		b[2] = c.NodeCount
+		b[3] = c.BurstCount
+		b[4] = c.BurstTimer
+	}
+	i := plcaInfoLen
+	for _, s := range strs {
+		s = s[:min(len(s), maxStrLen)]
+		b[i] = byte(len(s))
+		i += 1 + copy(b[i+1:], s)
+	}
+	return i, nil
+}
+
+func (r *Reply) decode(b []byte) error {
+	if len(b) < plcaInfoLen {
+		return ErrMalformed
+	}
+	if b[0]&plcaEnabled != 0 {
+		r.PLCA = &t1s.PLCAConf{
+			NodeID:     b[1],
+			NodeCount:  b[2],
+			BurstCount: b[3],
+			BurstTimer: b[4],
+		}
+	}
+	b = b[plcaInfoLen:]
+	for _, s := range [...]*string{&r.Name, &r.ChipRevision, &r.Firmware} {
+		if len(b) < 1 || len(b) < 1+int(b[0]) {
+			return ErrMalformed
+		}
+		*s = string(b[1 : 1+b[0]])
+		b = b[1+b[0]:]
+	}
+	return nil
+}
+
+// Report is the result of Analyze.
+type Report struct {
+	Nodes     []Reply
+	Conflicts []*t1s.SegmentConflict
+	Gaps      []int // unused node IDs, see t1s.SegmentGaps
+}
+
+// Analyze checks the PLCA settings of the nodes that replied to a probe,
+// which should include the prober itself, as returned by Agent.Self.
+// Nodes are named in conflicts by their Label.
+func Analyze(nodes []Reply) *Report {
+	r := &Report{Nodes: append([]Reply(nil), nodes...)}
+	sortReplies(r.Nodes)
+	seg := make([]t1s.SegmentNode, len(r.Nodes))
+	for i := range r.Nodes {
+		n := &r.Nodes[i]
+		seg[i] = t1s.SegmentNode{Name: n.Label(), PLCA: n.PLCA}
+	}
+	r.Conflicts = t1s.CheckSegment(seg)
+	r.Gaps = t1s.SegmentGaps(seg)
+	return r
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func putBE32(b []byte, v uint32) {
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+}
@@ -0,0 +1,208 @@
+package t1s
+
+import (
+	"errors"
+)
+
+// Sizes of Ethernet frames and their fields, according to IEEE 802.3.
+const (
+	AddrLen    = 6
+	HeaderLen  = 2*AddrLen + 2 // destination, source, EtherType
+	VLANTagLen = 4             // optional 802.1Q tag
+	FCSLen     = 4
+
+	// MinFrameLen and MaxFrameLen include the FCS, but not a VLAN tag.
+	MinFrameLen = 64
+	MaxFrameLen = 1518
+
+	// MinPaddedLen is the length, excluding the FCS,
+	// frames are padded to before transmission.
+	MinPaddedLen = MinFrameLen - FCSLen
+
+	MaxPayloadLen = MaxFrameLen - HeaderLen - FCSLen
+)
+
+// EtherType identifies the protocol of an Ethernet frame's payload.
+// Values below 0x0600 denote the payload length.
+type EtherType uint16
+
+const (
+	EtherTypeIPv4      EtherType = 0x0800
+	EtherTypeARP       EtherType = 0x0806
+	EtherTypeVLAN      EtherType = 0x8100 // 802.1Q tag
+	EtherTypeIPv6      EtherType = 0x86DD
+	EtherTypeLocalExp1 EtherType = 0x88B5 // IEEE 802 local experimental
+	EtherTypeLocalExp2 EtherType = 0x88B6
+	EtherTypeQinQ      EtherType = 0x88A8 // 802.1ad service tag
+	EtherTypeLLDP      EtherType = 0x88CC
+
+	// MinEtherType is the smallest value interpreted as EtherType.
+	MinEtherType EtherType = 0x0600
+)
+
+// BroadcastAddr is the Ethernet broadcast address.
+var BroadcastAddr = [AddrLen]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// IsMulticast reports whether addr is a group address,
+// including the broadcast address.
+func IsMulticast(addr [AddrLen]byte) bool {
+	return addr[0]&1 != 0
+}
+
+var (
+	ErrShortFrame  = errors.New("t1s: frame too short")
+	ErrShortBuffer = errors.New("t1s: buffer too small")
+)
+
+// VLANTag is the tag control information of an 802.1Q tag.
+type VLANTag struct {
+	PCP uint8  // priority code point, 0..7
+	DEI bool   // drop eligible indicator
+	VID uint16 // VLAN identifier, 0..4095
+}
+
+// ParseTCI decodes a tag control information field.
+func ParseTCI(tci uint16) VLANTag {
+	return VLANTag{
+		PCP: uint8(tci >> 13),
+		DEI: tci&(1<<12) != 0,
+		VID: tci & 0x0FFF,
+	}
+}
+
+// TCI returns the encoded tag control information.
+func (t VLANTag) TCI() uint16 {
+	tci := uint16(t.PCP&7)<<13 | t.VID&0x0FFF
+	if t.DEI {
+		tci |= 1 << 12
+	}
+	return tci
+}
+
+// Frame is a view of an Ethernet frame, starting with the destination
+// address. Whether it contains an FCS is up to the user. The accessors
+// require the frame to be valid, see [Frame.Valid].
+type Frame []byte
+
+// Valid reports whether f is long enough to contain
+// an Ethernet header, including a VLAN tag, if present.
+func (f Frame) Valid() bool {
+	if len(f) < HeaderLen {
+		return false
+	}
+	return !f.Tagged() || len(f) >= HeaderLen+VLANTagLen
+}
+
+func (f Frame) Dst() [AddrLen]byte {
+	return [AddrLen]byte(f[0:AddrLen])
+}
+
+func (f Frame) Src() [AddrLen]byte {
+	return [AddrLen]byte(f[AddrLen : 2*AddrLen])
+}
+
+// Tagged reports whether the frame contains an 802.1Q tag.
+func (f Frame) Tagged() bool {
+	return EtherType(be16(f[2*AddrLen:])) == EtherTypeVLAN
+}
+
+// VLANTag returns the frame's 802.1Q tag, if present.
+func (f Frame) VLANTag() (tag VLANTag, ok bool) {
+	if !f.Tagged() {
+		return tag, false
+	}
+	return ParseTCI(be16(f[2*AddrLen+2:])), true
+}
+
+// EtherType returns the EtherType following the
+// addresses and an optional VLAN tag.
+func (f Frame) EtherType() EtherType {
+	return EtherType(be16(f[f.HeaderLen()-2:]))
+}
+
+// HeaderLen returns the length of the header, including a VLAN tag.
+func (f Frame) HeaderLen() int {
+	if f.Tagged() {
+		return HeaderLen + VLANTagLen
+	}
+	return HeaderLen
+}
+
+// Payload returns the data following the header.
+func (f Frame) Payload() []byte {
+	return f[f.HeaderLen():]
+}
+
+// Header describes the header of a frame to be built.
+type Header struct {
+	Dst, Src  [AddrLen]byte
+	Tagged    bool // whether VLAN is inserted
+	VLAN      VLANTag
+	EtherType EtherType
+}
+
+// Len returns the length of the encoded header.
+func (h *Header) Len() int {
+	if h.Tagged {
+		return HeaderLen + VLANTagLen
+	}
+	return HeaderLen
+}
+
+// Put writes the header to the start of buf, and
+// returns the number of bytes written.
+func (h *Header) Put(buf []byte) (int, error) {
+	n := h.Len()
+	if len(buf) < n {
+		return 0, ErrShortBuffer
+	}
+	copy(buf[0:], h.Dst[:])
+	copy(buf[AddrLen:], h.Src[:])
+	i := 2 * AddrLen
+	if h.Tagged {
+		putBE16(buf[i:], uint16(EtherTypeVLAN))
+		putBE16(buf[i+2:], h.VLAN.TCI())
+		i += VLANTagLen
+	}
+	putBE16(buf[i:], uint16(h.EtherType))
+	return n, nil
+}
+
+// BuildFrame writes a frame consisting of header h and payload to buf,
+// which, in an implementation of [UpperProto.PollForEth], may be the
+// buffer passed by the driver. The frame is padded to MinPaddedLen.
+// The length of the frame is returned.
+func BuildFrame(buf []byte, h *Header, payload []byte) (int, error) {
+	n, err := h.Put(buf)
+	if err != nil {
+		return 0, err
+	}
+	if len(buf)-n < len(payload) {
+		return 0, ErrShortBuffer
+	}
+	n += copy(buf[n:], payload)
+	return Pad(buf, n)
+}
+
+// Pad fills buf with zeroes from position n up to MinPaddedLen,
+// and returns the resulting length of the frame, which is n,
+// if the frame is long enough already.
+func Pad(buf []byte, n int) (int, error) {
+	if n >= MinPaddedLen {
+		return n, nil
+	}
+	if len(buf) < MinPaddedLen {
+		return 0, ErrShortBuffer
+	}
+	clear(buf[n:MinPaddedLen])
+	return MinPaddedLen, nil
+}
+
+func be16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func putBE16(b []byte, v uint16) {
+	b[0] = byte(v >> 8)
+	b[1] = byte(v)
+}
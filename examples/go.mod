@@ -4,7 +4,13 @@ go 1.22.2
 
 require (
 	github.com/knieriem/t1s v0.0.0-20240506205313-189e7e6390fe
+	github.com/knieriem/t1s/lan865x/periphdev v0.0.0-00010101000000-000000000000
 	github.com/soypat/seqs v0.0.0-20240421220819-60c7db9451e0
 	periph.io/x/conn/v3 v3.7.0
 	periph.io/x/host/v3 v3.8.2
 )
+
+replace (
+	github.com/knieriem/t1s => ../
+	github.com/knieriem/t1s/lan865x/periphdev => ../lan865x/periphdev
+)
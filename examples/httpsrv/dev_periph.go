@@ -8,134 +8,113 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	"periph.io/x/conn/v3/gpio"
-	"periph.io/x/conn/v3/gpio/gpioreg"
-	"periph.io/x/conn/v3/physic"
-	"periph.io/x/conn/v3/spi"
-	"periph.io/x/conn/v3/spi/spireg"
-	"periph.io/x/host/v3"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/metrics"
+	"github.com/knieriem/t1s/lan865x/periphdev"
+	"github.com/knieriem/t1s/lan865x/slogger"
+	"github.com/knieriem/t1s/nodeconf"
 	"periph.io/x/host/v3/rpi"
 )
 
-type hwIntf struct {
-	spiConn       spi.Conn
-	spiPortCloser spi.PortCloser
-	log           *slog.Logger
-}
-
-func (d *hwIntf) IntrActive() bool {
-	active := intrPin.Read() == false
-	if active {
-		d.log.Info("intr")
-	}
-	return active
-}
-
 var (
-	traceEth bool
+	traceEth  bool
+	useCSMACD bool
 
-	resetPinName = flag.String("reset-pin", "GPIO13", "name of LAN865x reset pin")
-	intrPinName  = flag.String("intr-pin", "GPIO26", "name of LAN865x interrupt pin")
-	spidevName   = flag.String("spidev", "/dev/spidev0.1", "name of the SPI device")
+	hwConf = periphdev.DefaultConf
 )
-var resetPin gpio.PinOut
-var intrPin gpio.PinIn
-
-func (d *hwIntf) Reset() error {
-	if false {
-		resetPin.Out(gpio.Low)
-		time.Sleep(10 * time.Millisecond)
-		resetPin.Out(gpio.High)
-		time.Sleep(10 * time.Millisecond)
-	}
-	return nil
-}
-
-func (d *hwIntf) SpiTxRx(tx, rx []byte, done func(error)) error {
-	// log.Printf("spi <- % x", tx)
-	err := d.spiConn.Tx(tx, rx)
-	// log.Printf("spi -> % x", rx)
-	done(err)
-	return err
-}
-
-func (d *hwIntf) setupSPI(spidev string) error {
-	port, err := spireg.Open(spidev)
-	if err != nil {
-		return err
-	}
-	c, err := port.Connect(5*physic.MegaHertz, spi.Mode0, 8)
-	if err != nil {
-		port.Close()
-		return err
-	}
-	d.spiPortCloser = port
-	d.spiConn = c
-	return nil
-}
 
-func initPlatform() (mainLog, srvLog *slog.Logger, hwi *hwIntf) {
-	useCSMACD := false
-	logLevelSpec := "main=i,srv=e,t1s=e"
+func initPlatform() (mainLog, srvLog *slog.Logger, hwi lan865x.HwIntf) {
+	logLevelSpec := ""
+	configFile := ""
+	metricsAddr := ""
+	flag.StringVar(&metricsAddr, "metrics", metricsAddr, "serve metrics at /metrics and /debug/vars on the host network at `addr`")
+	flag.StringVar(&configFile, "config", configFile, "read the node configuration from `file`; flags take precedence")
 	flag.BoolVar(&useCSMACD, "csmacd", useCSMACD, "use CSMA/CD, disable PLCA")
 	flag.UintVar(&plcaNodeID, "plca-id", plcaNodeID, "PLCA node id")
 	flag.UintVar(&plcaNodeCount, "plca-count", plcaNodeCount, "PLCA node count")
+	flag.BoolVar(&plcaAuto, "plca-auto", plcaAuto, "request a PLCA node ID from the coordinator, using -plca-id as fallback")
 	flag.StringVar(&ipAddr, "ip", ipAddr, "IP address")
 	flag.BoolVar(&noRepeat, "svc-no-repeat", noRepeat, "skip service repetition")
 	flag.DurationVar(&svcPause, "svc-pause", svcPause, "service pause duration")
-	flag.StringVar(&logLevelSpec, "D", logLevelSpec, "log levels specification")
+	flag.StringVar(&logLevelSpec, "D", logLevelSpec, "log levels specification, like \"main=i,srv=e,t1s=e\"")
 	flag.BoolVar(&traceEth, "E", false, "enable ethernet packet traces")
+	hwConf.RegisterFlags(flag.CommandLine)
 	flag.Parse()
-
+	if configFile != "" {
+		err := loadConfig(configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// parse again, so that flags override the configuration
+		flag.Parse()
+	}
 	if useCSMACD {
 		inst.PLCA = nil
+	} else {
+		plca.NodeID = uint8(plcaNodeID)
+		plca.NodeCount = uint8(plcaNodeCount)
+		inst.PLCA = &plca
+	}
+
+	if metricsAddr != "" {
+		http.Handle("/metrics", metrics.Handler(&inst))
+		metrics.Publish("lan865x", &inst)
+		go func() {
+			log.Fatal(http.ListenAndServe(metricsAddr, nil))
+		}()
 	}
 
-	_, err := host.Init()
+	dev, err := periphdev.Open(&hwConf)
 	if err != nil {
-		log.Fatalf("failed to initialize periph: %v", err)
+		log.Fatalf("failed to initialize hardware: %v", err)
 	}
 
 	if !rpi.Present() {
 		log.Fatal("not running on an RPi")
 	}
 
-	err = updateLogLevelsFromSpec(logLevelSpec)
-	if err != nil {
-		log.Fatal(err)
+	if logLevelSpec != "" {
+		err = updateLogLevelsFromSpec(logLevelSpec)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 	mainLog = newTextLogger(mainLogLevel).WithGroup("main")
 	srvLog = newTextLogger(srvLogLevel)
-	t1sLog := newTextLogger(logLevel).WithGroup("t1s")
-	inst.DebugInfo = t1sLog.Info
-	inst.DebugError = t1sLog.Error
-	hw.log = mainLog
-
-	resetPin = lookupPin(*resetPinName)
-	resetPin.Out(gpio.High)
-
-	intrPin = lookupPin(*intrPinName)
-	intrPin.In(gpio.PullNoChange, gpio.NoEdge)
-
-	err = hw.setupSPI(*spidevName)
-	if err != nil {
-		log.Fatalf("failed to initialize spidev: %v", err)
-	}
-
-	return mainLog, srvLog, &hw
+	t1sLog := newTextLogger(t1sLogLevel).WithGroup("t1s")
+	inst.Log = slogger.New(t1sLog.Handler())
+	hwi = dev.HwIntf(t1sLog.WithGroup("spi"))
+	return mainLog, srvLog, hwi
 }
 
-func lookupPin(name string) gpio.PinIO {
-	pin := gpioreg.ByName(name)
-	if pin == nil {
-		log.Fatal("pin not found:", name)
+func loadConfig(file string) error {
+	c, err := nodeconf.LoadFile(file)
+	if err != nil {
+		return err
 	}
-	return pin
+	inst.MAC = c.MACConf()
+	macAddr = inst.MAC.Addr
+	if p := c.PLCAConf(); p != nil {
+		plca = *p
+		plcaNodeID = uint(p.NodeID)
+		plcaNodeCount = uint(p.NodeCount)
+	} else {
+		useCSMACD = true
+	}
+	if p := c.IPPrefix(); p.IsValid() {
+		ipAddr = p.Addr().String()
+	}
+	hwConf.Apply(&c.Hardware)
+	mainLogLevel = c.LogLevel("main", mainLogLevel)
+	srvLogLevel = c.LogLevel("srv", srvLogLevel)
+	t1sLogLevel = c.LogLevel("t1s", t1sLogLevel)
+	return nil
 }
 
 type ticksProvider struct {
@@ -146,8 +125,6 @@ func (tp *ticksProvider) Milliseconds() uint32 {
 	return uint32(time.Since(tp.t0) / 1e6)
 }
 
-var hw hwIntf
-
 var mainLogLevel = slog.LevelInfo
 var srvLogLevel = slog.LevelError
 var t1sLogLevel = slog.LevelError
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/knieriem/t1s/examples/internal/tinygo/spi"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/slogger"
 )
 
 type hwIntf struct {
@@ -41,14 +43,13 @@ var hw = hwIntf{
 	intrPin: intrPin,
 }
 
-func initPlatform() (srvLog, t1sLog *slog.Logger, hwi *hwIntf) {
+func initPlatform() (srvLog, t1sLog *slog.Logger, hwi lan865x.HwIntf) {
 	logger := newTextLogger(logLevel)
 	t1sLog = logger.WithGroup("t1s")
 	mainLog := logger.WithGroup("main")
 	hw.log = mainLog
 
-	inst.DebugInfo = t1sLog.Info
-	inst.DebugError = t1sLog.Error
+	inst.Log = slogger.New(t1sLog.Handler())
 
 	pinout(csLAN865x)
 	mLED.Init()
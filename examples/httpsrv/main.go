@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"time"
 
 	"github.com/soypat/seqs/stacks"
@@ -8,6 +9,8 @@ import (
 	"github.com/knieriem/t1s"
 	"github.com/knieriem/t1s/examples/internal/soypat-cyw43439/httpsrv"
 	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/metrics"
+	"github.com/knieriem/t1s/plcaid"
 )
 
 type proto struct {
@@ -31,6 +34,10 @@ var (
 
 	plcaNodeID    uint = 1
 	plcaNodeCount uint = 8
+
+	// plcaAuto enables the automatic assignment of a PLCA node ID;
+	// the configured PLCA settings are used as fallback.
+	plcaAuto = false
 )
 
 var inst = lan865x.Inst{
@@ -50,6 +57,10 @@ var plca = t1s.PLCAConf{
 var (
 	noRepeat = false
 	svcPause = 10 * time.Millisecond
+
+	// statusInterval is the interval of updates of
+	// the PLCA status exported as metrics.
+	statusInterval = 5 * time.Second
 )
 
 func main() {
@@ -59,16 +70,36 @@ func main() {
 	inst.Dev = hwIntf
 
 	httpsrv.SetLED = setLED
+	httpsrv.WriteMetrics = func(w io.Writer) {
+		s := inst.Stats()
+		metrics.WritePrometheus(w, &s)
+	}
 	stack := httpsrv.Setup(srvLog, ipAddr, macAddr)
-	inst.UpperProto = &proto{stack: stack}
+	var up t1s.UpperProto = &proto{stack: stack}
+	if plcaAuto {
+		up = &plcaid.Client{
+			MAC:      inst.MAC,
+			SetPLCA:  inst.SetPLCA,
+			Fallback: inst.PLCA,
+			Ticks:    lan865x.Ticks,
+			Next:     up,
+		}
+		inst.PLCA = nil
+	}
+	inst.UpperProto = up
 
-	if ok := inst.Init(); !ok {
-		log.Error("init failed")
+	if err := inst.Init(); err != nil {
+		log.Error("init failed", "err", err)
 		return
 	}
-	log.Info("init done")
+	log.Info("init done", "chip", inst.ChipInfo().String())
 
+	var tStatus time.Time
 	for {
+		if time.Since(tStatus) >= statusInterval {
+			tStatus = time.Now()
+			inst.UpdateStatus()
+		}
 		for {
 			done := inst.Service()
 			if done || noRepeat {
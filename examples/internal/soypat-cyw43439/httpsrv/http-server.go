@@ -2,6 +2,7 @@ package httpsrv
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"log/slog"
 	"net/netip"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/knieriem/t1s/examples/internal/soypat-cyw43439/common"
 	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/metrics"
 )
 
 const connTimeout = 3 * time.Second
@@ -56,6 +58,19 @@ func httpHandler(respWriter io.Writer, resp *httpx.ResponseHeader, req *httpx.Re
 		lastLedState = !lastLedState
 		SetLED(lastLedState)
 
+	case "/metrics":
+		if WriteMetrics == nil {
+			resp.SetStatusCode(404)
+			respWriter.Write(resp.Header())
+			break
+		}
+		metricsBuf.Reset()
+		WriteMetrics(&metricsBuf)
+		resp.SetContentType(metrics.ContentType)
+		resp.SetContentLength(metricsBuf.Len())
+		respWriter.Write(resp.Header())
+		respWriter.Write(metricsBuf.Bytes())
+
 	default:
 		println("Path not found:", uri)
 		resp.SetStatusCode(404)
@@ -65,6 +80,12 @@ func httpHandler(respWriter io.Writer, resp *httpx.ResponseHeader, req *httpx.Re
 
 var SetLED = func(state bool) {}
 
+// WriteMetrics, if set, writes the body of responses
+// to requests of /metrics, in the Prometheus text format.
+var WriteMetrics func(w io.Writer)
+
+var metricsBuf bytes.Buffer
+
 func Setup(logger *slog.Logger, ipAddr string, mac [6]byte) *stacks.PortStack {
 	stack, err := common.SetupWithDHCP(common.SetupConfig{
 		MAC:         mac,
@@ -0,0 +1,112 @@
+package lan865x
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// ChipInfo identifies a MAC-PHY.
+type ChipInfo struct {
+	PHYID    uint32 // contents of OA_PHYID
+	Model    uint16 // 0x8650 or 0x8651
+	Revision uint8  // silicon revision
+
+	// TC6Version is the version of the OPEN Alliance TC6 protocol
+	// implemented: the major version in bits 4..7, the minor in bits 0..3.
+	TC6Version uint8
+}
+
+// Identification of LAN8650/1 PHYs within OA_PHYID.
+const (
+	chipOUI      = 0x1F0
+	chipPHYModel = 0x1B
+)
+
+// OUI returns the organizationally unique identifier contained in the PHY ID.
+func (c ChipInfo) OUI() uint32 {
+	return c.PHYID >> 10
+}
+
+// PHYModel returns the model number contained in the PHY ID.
+func (c ChipInfo) PHYModel() uint16 {
+	return uint16(c.PHYID >> 4 & 0x3FF)
+}
+
+// Supported reports whether c describes a LAN8650 or LAN8651
+// of a silicon revision supported by the oa-tc6 library.
+func (c ChipInfo) Supported() bool {
+	return c.OUI() == chipOUI && c.PHYModel() == chipPHYModel &&
+		(c.Model == 0x8650 || c.Model == 0x8651) && c.Revision != 0
+}
+
+// String returns the chip's name and revision, like "LAN8651 rev 2".
+func (c ChipInfo) String() string {
+	return "LAN" + strconv.FormatUint(uint64(c.Model), 16) + " rev " + strconv.Itoa(int(c.Revision))
+}
+
+// TC6VersionString returns the TC6 protocol version, like "1.1".
+func (c ChipInfo) TC6VersionString() string {
+	return strconv.Itoa(int(c.TC6Version>>4)) + "." + strconv.Itoa(int(c.TC6Version&0xF))
+}
+
+// UnsupportedChipError is returned by [Inst.Init]
+// if the MAC-PHY is not supported.
+type UnsupportedChipError struct {
+	Info ChipInfo
+}
+
+func (e *UnsupportedChipError) Error() string {
+	c := &e.Info
+	return "lan865x: unsupported chip: PHY ID 0x" + strconv.FormatUint(uint64(c.PHYID), 16) +
+		", model " + strconv.FormatUint(uint64(c.Model), 16) +
+		", revision " + strconv.Itoa(int(c.Revision))
+}
+
+var (
+	ErrNoTicks    = errors.New("lan865x: no ticks provider")
+	ErrNoInstance = errors.New("lan865x: no oa-tc6 instance available")
+	ErrInitFailed = errors.New("lan865x: initialization failed")
+)
+
+// ChipInfo returns the identification of the MAC-PHY,
+// as read by a successful [Inst.Init].
+func (inst *Inst) ChipInfo() ChipInfo {
+	return inst.chip
+}
+
+func (inst *Inst) readChipInfo() (c ChipInfo, err error) {
+	c.PHYID, err = inst.ReadReg(tc6.RegPHYID)
+	if err != nil {
+		return c, err
+	}
+	devID, err := inst.ReadReg(tc6.RegDevID)
+	if err != nil {
+		return c, err
+	}
+	ver, err := inst.ReadReg(tc6.RegIDVer)
+	if err != nil {
+		return c, err
+	}
+	c.Model = uint16(devID >> 4)
+	c.Revision = uint8(devID & 0xF)
+	c.TC6Version = uint8(ver)
+	return c, nil
+}
+
+// initError determines why the register layer of the
+// oa-tc6 library failed to initialize the MAC-PHY.
+func (inst *Inst) initError() error {
+	if inst.initErr != nil {
+		return inst.initErr
+	}
+	c, err := inst.readChipInfo()
+	if err != nil {
+		return ErrInitFailed
+	}
+	if !c.Supported() {
+		return &UnsupportedChipError{Info: c}
+	}
+	return ErrInitFailed
+}
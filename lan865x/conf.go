@@ -0,0 +1,36 @@
+package lan865x
+
+// #include "tc6-conf.h"
+import "C"
+
+// Parameters of the TC6 SPI protocol implementation, as
+// fixed at compile time by tc6-conf.h.
+//
+// The defaults may be changed using build tags:
+//
+//	lan865x_chunk32    use 32-byte instead of 64-byte chunks
+//	lan865x_xact8      limit SPI bursts to 8 chunks
+//	lan865x_noconcat   disable concatenation of TX frames within chunks
+//
+// Other values may be specified by defining the corresponding macros
+// via CGO_CFLAGS, e.g. CGO_CFLAGS=-DTC6_CHUNKS_XACT=4u.
+// The CONFIG0 register of the MAC-PHY is programmed
+// according to the chunk size selected.
+const (
+	// ChunkSize is the payload size of a single TC6 data chunk.
+	ChunkSize = C.TC6_CHUNK_SIZE
+
+	// ChunksPerXact is the maximum number of chunks
+	// transferred within a single SPI transaction.
+	ChunksPerXact = C.TC6_CHUNKS_XACT
+
+	// ConcatThreshold is the frame length up to which the
+	// start of a TX frame may be placed into the chunk
+	// containing the end of the previous frame.
+	ConcatThreshold = C.TC6_CONCAT_THRESHOLD
+
+	// SPIBufSize is the maximum length of the tx and rx buffers
+	// passed to [HwIntf.SpiTxRx], which may be used
+	// to size DMA buffers.
+	SPIBufSize = ChunksPerXact * (ChunkSize + C.TC6_HEADER_SIZE)
+)
@@ -0,0 +1,6 @@
+//go:build lan865x_chunk32
+
+package lan865x
+
+// #cgo CFLAGS: -DTC6_CHUNK_SIZE=32u
+import "C"
@@ -0,0 +1,6 @@
+//go:build lan865x_noconcat
+
+package lan865x
+
+// #cgo CFLAGS: -DTC6_CONCAT_THRESHOLD=0u
+import "C"
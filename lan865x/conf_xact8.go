@@ -0,0 +1,6 @@
+//go:build lan865x_xact8
+
+package lan865x
+
+// #cgo CFLAGS: -DTC6_CHUNKS_XACT=8u
+import "C"
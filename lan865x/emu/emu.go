@@ -0,0 +1,553 @@
+// Package emu emulates a LAN865x MAC-PHY at the level of the TC6 SPI
+// protocol, so that the driver can be run without hardware.
+//
+// An emulated MAC-PHY implements [tc6.HwIntf]. It is attached to
+// a simulated mixing segment via a [bussim.Port]. Supported are
+// control transactions, with and without protection, data chunks
+// in both directions including transmit credits and receive chunks
+// available, the interrupt line, soft reset, the MAC's address filters,
+// the PLCA configuration registers, and sleep with wake-up by the bus
+// or the WAKE_IN pin, see [MACPHY.Wake]. Timestamping, cut-through,
+// wake-up forwarding, and most vendor specific registers are not
+// emulated; writes to unknown registers are stored, so that they read back.
+package emu
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"sync"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// Conf contains the properties of an emulated MAC-PHY.
+type Conf struct {
+	Model     uint16 // 0x8650 or 0x8651; 0x8650 if zero
+	Rev       uint8  // silicon revision; 2 if zero
+	ChunkSize int    // tc6.DefaultChunkSize if zero
+}
+
+// Bits of status and control registers.
+const (
+	status0TXPE   = 1 << 0
+	status0TXBOE  = 1 << 1
+	status0RXBOE  = 1 << 3
+	status0HDRE   = 1 << 5
+	status0RESETC = 1 << 6
+	status0CDPE   = 1 << 12
+
+	config0Sync = 1 << 15
+
+	ncrRXEN = 1 << 2
+	ncrTXEN = 1 << 3
+
+	ncfgrCopyAll   = 1 << 4
+	ncfgrNoBcast   = 1 << 5
+	ncfgrMultiHash = 1 << 6
+	ncfgrUniHash   = 1 << 7
+
+	plcaEnable = 1 << 15
+	plcaStatus = 1 << 15
+
+	sleepEnable  = 1 << 15
+	sleepWakeIn  = 1 << 14
+	sleepWakeMDI = 1 << 13
+)
+
+// Registers used for indirect access to configuration parameters.
+const (
+	regIndirAddr  = 0x000400D8
+	regIndirData  = 0x000400D9
+	regIndirCtrl  = 0x000400DA
+	indirReadCmd  = 0x0002
+	indirParamSel = 0x0005 // configuration parameter checked by the driver
+)
+
+const (
+	txBufSize  = 8 * 1024
+	rxBufSize  = 16 * 1024
+	maxCredits = 31
+)
+
+// MACPHY is an emulated LAN865x.
+type MACPHY struct {
+	mu        sync.Mutex
+	conf      Conf
+	port      *bussim.Port
+	regs      map[uint32]uint32
+	saValid   [4]bool
+	plca      *t1s.PLCAConf
+	irq       bool
+	chunkSize int
+	asleep    bool
+	wakeConf  uint32 // DEEP_SLEEP_CTRL_0 at sleep entry
+
+	tx       []byte
+	txActive bool
+	txQueued []int // lengths of frames waiting at the port
+
+	rx     [][]byte
+	rxOff  int
+	rxUsed int
+}
+
+// New creates an emulated MAC-PHY and attaches it to bus. If conf
+// is nil, default values are used. The device is in the state
+// after a hardware reset.
+func New(bus *bussim.Bus, name string, conf *Conf) *MACPHY {
+	m := new(MACPHY)
+	if conf != nil {
+		m.conf = *conf
+	}
+	if m.conf.Model == 0 {
+		m.conf.Model = 0x8650
+	}
+	if m.conf.Rev == 0 {
+		m.conf.Rev = 2
+	}
+	m.chunkSize = m.conf.ChunkSize
+	if m.chunkSize == 0 {
+		m.chunkSize = tc6.DefaultChunkSize
+	}
+	m.port = bus.Attach(name, m.recv)
+	m.port.QueueLen = txBufSize / 64
+	m.reset()
+	return m
+}
+
+// Port returns the port attaching m to the bus.
+func (m *MACPHY) Port() *bussim.Port {
+	return m.port
+}
+
+// Reg returns the current value of a register, as it would be
+// read via SPI, without side effects.
+func (m *MACPHY) Reg(addr uint32) uint32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.read(addr)
+}
+
+// Reset performs a hardware reset.
+func (m *MACPHY) Reset() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reset()
+	return nil
+}
+
+// IntrActive reports whether the interrupt line is asserted.
+func (m *MACPHY) IntrActive() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.irq && !m.asleep
+}
+
+// Wake pulses the WAKE_IN pin. If m is asleep, and wake-up
+// by WAKE_IN has been enabled, m wakes up.
+func (m *MACPHY) Wake() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.asleep && m.wakeConf&sleepWakeIn != 0 {
+		m.reset()
+	}
+	return nil
+}
+
+// Asleep reports whether m is asleep.
+func (m *MACPHY) Asleep() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.asleep
+}
+
+// SpiTxRx performs an SPI transfer. The done function
+// is called before SpiTxRx returns.
+func (m *MACPHY) SpiTxRx(tx, rx []byte, done func(err error)) error {
+	m.mu.Lock()
+	clear(rx)
+	if len(tx) >= tc6.HeaderSize && !m.asleep {
+		if tc6.IsData(tx) {
+			m.data(tx, rx)
+		} else {
+			m.ctrl(tx, rx)
+		}
+	}
+	m.mu.Unlock()
+	done(nil)
+	return nil
+}
+
+func (m *MACPHY) reset() {
+	rev := uint32(m.conf.Rev & 0xF)
+	m.regs = map[uint32]uint32{
+		tc6.RegIDVer:       0x11,
+		tc6.RegPHYID:       0x0007C1B0 | rev,
+		tc6.RegConfig0:     0x0006,
+		tc6.RegStatus0:     status0RESETC,
+		tc6.RegIMask0:      0x00001FBF,
+		tc6.RegIMask1:      0xFFFFFFFF,
+		tc6.RegPLCACtrl1:   0x08FF,
+		tc6.RegPLCATOTimer: 0x20,
+		tc6.RegPLCABurst:   0x0080,
+		tc6.RegDevID:       uint32(m.conf.Model)<<4 | rev,
+	}
+	m.saValid = [4]bool{}
+	m.tx = m.tx[:0]
+	m.txActive = false
+	m.txQueued = nil
+	m.rx = nil
+	m.rxOff = 0
+	m.rxUsed = 0
+	m.plca = nil
+	m.port.Configure(nil)
+	m.irq = true
+	m.asleep = false
+}
+
+// sleep powers m down; like after a wake-up, it
+// will be in the state following a reset.
+func (m *MACPHY) sleep() {
+	m.asleep = true
+	m.wakeConf = m.regs[tc6.RegDeepSleepCtrl0]
+	m.plca = nil
+	m.port.Configure(nil)
+}
+
+func (m *MACPHY) setStatus0(bits uint32) {
+	m.regs[tc6.RegStatus0] |= bits
+	if bits&^m.regs[tc6.RegIMask0] != 0 {
+		m.irq = true
+	}
+}
+
+func (m *MACPHY) exst() bool {
+	return m.regs[tc6.RegStatus0]&^m.regs[tc6.RegIMask0] != 0 ||
+		m.regs[tc6.RegStatus1]&^m.regs[tc6.RegIMask1] != 0
+}
+
+func (m *MACPHY) ctrl(tx, rx []byte) {
+	h := tc6.CtrlHeader(tc6.Word(tx))
+	n := h.NumRegs()
+	var protected bool
+	switch len(tx) {
+	case tc6.CtrlXactLen(n, false):
+	case tc6.CtrlXactLen(n, true):
+		protected = true
+	default:
+		return
+	}
+	if !h.ParityOK() {
+		m.setStatus0(status0HDRE)
+		return
+	}
+	copy(rx[tc6.HeaderSize:], tx[:tc6.HeaderSize])
+	w := 4
+	if protected {
+		w = 8
+	}
+	addr := h.Addr()
+	for i := 0; i < n; i++ {
+		a := addr
+		if !h.AID() {
+			a += uint32(i)
+		}
+		if h.Write() {
+			p := tx[tc6.HeaderSize+i*w:]
+			v := tc6.Word(p)
+			if protected && tc6.Word(p[4:]) != ^v {
+				m.setStatus0(status0CDPE)
+				continue
+			}
+			m.write(a, v)
+			continue
+		}
+		p := rx[2*tc6.HeaderSize+i*w:]
+		v := m.read(a)
+		tc6.PutWord(p, v)
+		if protected {
+			tc6.PutWord(p[4:], ^v)
+		}
+	}
+	if h.Write() {
+		copy(rx[2*tc6.HeaderSize:], tx[tc6.HeaderSize:len(tx)-tc6.HeaderSize])
+	}
+}
+
+func (m *MACPHY) read(addr uint32) uint32 {
+	switch addr {
+	case tc6.RegBufSts:
+		return uint32(m.txCredits())<<8 | uint32(m.rxChunksAvail())
+	case tc6.RegPLCAStatus:
+		if m.plca != nil && m.port.PLCAStatus() {
+			return plcaStatus
+		}
+		return 0
+	}
+	return m.regs[addr]
+}
+
+func (m *MACPHY) write(addr, v uint32) {
+	switch addr {
+	case tc6.RegIDVer, tc6.RegPHYID, tc6.RegStdCap, tc6.RegBufSts, tc6.RegPLCAStatus, tc6.RegDevID:
+		return
+	case tc6.RegReset:
+		if v&1 != 0 {
+			m.reset()
+		}
+		return
+	case tc6.RegStatus0, tc6.RegStatus1:
+		m.regs[addr] &^= v
+		return
+	case tc6.RegSpecAddr1Bot, tc6.RegSpecAddr2Bot, tc6.RegSpecAddr3Bot, tc6.RegSpecAddr4Bot:
+		m.saValid[(addr-tc6.RegSpecAddr1Bot)/2] = false
+	case tc6.RegSpecAddr1Top, tc6.RegSpecAddr2Top, tc6.RegSpecAddr3Top, tc6.RegSpecAddr4Top:
+		m.saValid[(addr-tc6.RegSpecAddr1Top)/2] = true
+	case regIndirCtrl:
+		if v == indirReadCmd {
+			m.regs[regIndirData] = m.indirect(m.regs[regIndirAddr])
+		}
+	}
+	m.regs[addr] = v
+	switch addr {
+	case tc6.RegPLCACtrl0, tc6.RegPLCACtrl1, tc6.RegPLCABurst:
+		m.configurePLCA()
+	case tc6.RegDeepSleepCtrl0:
+		if v&sleepEnable != 0 {
+			m.sleep()
+		}
+	}
+}
+
+// indirect returns the value of an internal configuration parameter.
+// Only the parameter checked by the driver for a valid chip
+// configuration is set; all trim offsets are zero.
+func (m *MACPHY) indirect(addr uint32) uint32 {
+	if addr == indirParamSel {
+		return 0x40
+	}
+	return 0
+}
+
+func (m *MACPHY) configurePLCA() {
+	var c *t1s.PLCAConf
+	if m.regs[tc6.RegPLCACtrl0]&plcaEnable != 0 {
+		ctrl1 := m.regs[tc6.RegPLCACtrl1]
+		burst := m.regs[tc6.RegPLCABurst]
+		c = &t1s.PLCAConf{
+			NodeID:     uint8(ctrl1),
+			NodeCount:  uint8(ctrl1 >> 8),
+			BurstCount: uint8(burst >> 8),
+			BurstTimer: uint8(burst),
+		}
+	}
+	if c == nil && m.plca == nil || c != nil && m.plca != nil && *c == *m.plca {
+		return
+	}
+	m.plca = c
+	m.port.Configure(c)
+}
+
+func (m *MACPHY) data(tx, rx []byte) {
+	m.irq = false
+	cs := m.chunkSize
+	n := cs + tc6.HeaderSize
+	sync := m.regs[tc6.RegConfig0]&config0Sync != 0
+	for off := 0; off+n <= len(tx); off += n {
+		h := tc6.DataHeader(tc6.Word(tx[off:]))
+		var ff tc6.FooterFields
+		ff.Sync = sync
+		switch {
+		case !sync:
+		case !h.ParityOK():
+			ff.HDRB = true
+			m.setStatus0(status0HDRE)
+		default:
+			if h.DV() {
+				m.txChunk(h, tx[off+tc6.HeaderSize:off+n])
+			}
+			if !h.NoRX() {
+				m.rxChunk(rx[off:off+cs], &ff)
+			}
+		}
+		ff.EXST = m.exst()
+		ff.TXC = m.txCredits()
+		ff.RCA = m.rxChunksAvail()
+		tc6.PutWord(rx[off+cs:], uint32(tc6.MakeFooter(&ff)))
+	}
+}
+
+func (m *MACPHY) txChunk(h tc6.DataHeader, data []byte) {
+	if m.txFree() < len(data) {
+		m.setStatus0(status0TXBOE)
+		m.txActive = false
+		return
+	}
+	sv, ev := h.SV(), h.EV()
+	swo, ebo := h.SWO()*4, h.EBO()
+	if sv && ev && ebo < swo {
+		// end of a frame, and start of the next one
+		m.txData(data[:ebo+1], false, true)
+		m.txData(data[swo:], true, false)
+		return
+	}
+	lo, hi := 0, len(data)
+	if sv {
+		lo = swo
+	}
+	if ev {
+		hi = ebo + 1
+	}
+	if hi < lo {
+		m.setStatus0(status0TXPE)
+		m.txActive = false
+		return
+	}
+	m.txData(data[lo:hi], sv, ev)
+}
+
+func (m *MACPHY) txData(b []byte, sv, ev bool) {
+	if sv {
+		if m.txActive {
+			m.setStatus0(status0TXPE)
+		}
+		m.txActive = true
+		m.tx = m.tx[:0]
+	} else if !m.txActive {
+		m.setStatus0(status0TXPE)
+		return
+	}
+	m.tx = append(m.tx, b...)
+	if !ev {
+		return
+	}
+	m.txActive = false
+	if m.regs[tc6.RegNetworkControl]&ncrTXEN == 0 {
+		return
+	}
+	if m.port.Send(m.tx) {
+		m.txQueued = append(m.txQueued, len(m.tx))
+	}
+}
+
+// txFree returns the number of bytes available in the transmit buffer.
+func (m *MACPHY) txFree() int {
+	for len(m.txQueued) > m.port.Pending() {
+		m.txQueued = m.txQueued[1:]
+	}
+	used := len(m.tx)
+	for _, n := range m.txQueued {
+		used += n
+	}
+	return txBufSize - used
+}
+
+func (m *MACPHY) txCredits() int {
+	return min(m.txFree()/m.chunkSize, maxCredits)
+}
+
+func (m *MACPHY) rxChunk(payload []byte, ff *tc6.FooterFields) {
+	if len(m.rx) == 0 {
+		return
+	}
+	f := m.rx[0]
+	k := copy(payload, f[m.rxOff:])
+	ff.DV = true
+	ff.SV = m.rxOff == 0
+	m.rxOff += k
+	if m.rxOff == len(f) {
+		ff.EV = true
+		ff.EBO = k - 1
+		m.rx = m.rx[1:]
+		m.rxUsed -= len(f)
+		m.rxOff = 0
+	}
+}
+
+func (m *MACPHY) rxChunksAvail() int {
+	cs := m.chunkSize
+	n := 0
+	for i, f := range m.rx {
+		k := len(f)
+		if i == 0 {
+			k -= m.rxOff
+		}
+		n += (k + cs - 1) / cs
+	}
+	return min(n, maxCredits)
+}
+
+// recv is called by the bus for each frame transmitted by another port.
+func (m *MACPHY) recv(frame []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.asleep {
+		if m.wakeConf&sleepWakeMDI != 0 {
+			m.reset()
+		}
+		return
+	}
+	if m.regs[tc6.RegNetworkControl]&ncrRXEN == 0 || !m.accept(frame) {
+		return
+	}
+	n := len(frame) + t1s.FCSLen
+	if m.rxUsed+n > rxBufSize {
+		m.setStatus0(status0RXBOE)
+		return
+	}
+	f := make([]byte, n)
+	copy(f, frame)
+	fcs := crc32.ChecksumIEEE(frame)
+	binary.LittleEndian.PutUint32(f[len(frame):], fcs)
+	m.rx = append(m.rx, f)
+	m.rxUsed += n
+	m.irq = true
+}
+
+// accept applies the MAC's address filters to frame.
+func (m *MACPHY) accept(frame []byte) bool {
+	if len(frame) < t1s.AddrLen {
+		return false
+	}
+	cfg := m.regs[tc6.RegNetworkConfig]
+	if cfg&ncfgrCopyAll != 0 {
+		return true
+	}
+	da := [6]byte(frame)
+	if da == t1s.BroadcastAddr {
+		return cfg&ncfgrNoBcast == 0
+	}
+	for i, valid := range m.saValid {
+		if valid && m.specAddr(i) == da {
+			return true
+		}
+	}
+	hashEn := uint32(ncfgrUniHash)
+	if t1s.IsMulticast(da) {
+		hashEn = ncfgrMultiHash
+	}
+	if cfg&hashEn == 0 {
+		return false
+	}
+	hash := uint64(m.regs[tc6.RegHashTop])<<32 | uint64(m.regs[tc6.RegHashBottom])
+	return hash&(1<<hashIndex(da)) != 0
+}
+
+func (m *MACPHY) specAddr(i int) [6]byte {
+	bot := m.regs[tc6.RegSpecAddr1Bot+uint32(2*i)]
+	top := m.regs[tc6.RegSpecAddr1Top+uint32(2*i)]
+	return [6]byte{byte(bot), byte(bot >> 8), byte(bot >> 16), byte(bot >> 24), byte(top), byte(top >> 8)}
+}
+
+// hashIndex returns the index into the MAC's hash register
+// calculated from a destination address.
+func hashIndex(addr [6]byte) uint {
+	var idx uint
+	for i := 0; i < 48; i++ {
+		b := uint(addr[i/8]>>(i%8)) & 1
+		idx ^= b << (i % 6)
+	}
+	return idx
+}
@@ -0,0 +1,110 @@
+package lan865x
+
+// #include <tc6.h>
+// #include <tc6-regs.h>
+import "C"
+
+import (
+	"strconv"
+)
+
+// Event is an event reported by the register layer of the
+// oa-tc6 library, mostly derived from the MAC-PHY's status registers.
+type Event uint8
+
+// EventInitFailed is reported by the driver itself, if applying
+// the address filters or the init profiles failed during
+// a reinitialization of the MAC-PHY, which is then retried,
+// or if the MAC-PHY has not been initialized in time after
+// a wake-up, see [Inst.Wake].
+const EventInitFailed Event = C.TC6Regs_Event_Unsupported_Hardware + 1
+
+var eventNames = [...]string{
+	C.TC6Regs_Event_UnknownError:                           "UnknownError",
+	C.TC6Regs_Event_Transmit_Protocol_Error:                "Transmit_Protocol_Error",
+	C.TC6Regs_Event_Transmit_Buffer_Overflow_Error:         "Transmit_Buffer_Overflow_Error",
+	C.TC6Regs_Event_Transmit_Buffer_Underflow_Error:        "Transmit_Buffer_Underflow_Error",
+	C.TC6Regs_Event_Receive_Buffer_Overflow_Error:          "Receive_Buffer_Overflow_Error",
+	C.TC6Regs_Event_Loss_of_Framing_Error:                  "Loss_of_Framing_Error",
+	C.TC6Regs_Event_Header_Error:                           "Header_Error",
+	C.TC6Regs_Event_Reset_Complete:                         "Reset_Complete",
+	C.TC6Regs_Event_PHY_Interrupt:                          "PHY_Interrupt",
+	C.TC6Regs_Event_Transmit_Timestamp_Capture_Available_A: "Transmit_Timestamp_Capture_Available_A",
+	C.TC6Regs_Event_Transmit_Timestamp_Capture_Available_B: "Transmit_Timestamp_Capture_Available_B",
+	C.TC6Regs_Event_Transmit_Timestamp_Capture_Available_C: "Transmit_Timestamp_Capture_Available_C",
+	C.TC6Regs_Event_Transmit_Frame_Check_Sequence_Error:    "Transmit_Frame_Check_Sequence_Error",
+	C.TC6Regs_Event_Control_Data_Protection_Error:          "Control_Data_Protection_Error",
+	C.TC6Regs_Event_RX_Non_Recoverable_Error:               "RX_Non_Recoverable_Error",
+	C.TC6Regs_Event_TX_Non_Recoverable_Error:               "TX_Non_Recoverable_Error",
+	C.TC6Regs_Event_FSM_State_Error:                        "FSM_State_Error",
+	C.TC6Regs_Event_SRAM_ECC_Error:                         "SRAM_ECC_Error",
+	C.TC6Regs_Event_Undervoltage:                           "Undervoltage",
+	C.TC6Regs_Event_Internal_Bus_Error:                     "Internal_Bus_Error",
+	C.TC6Regs_Event_TX_Timestamp_Capture_Overflow_A:        "TX_Timestamp_Capture_Overflow_A",
+	C.TC6Regs_Event_TX_Timestamp_Capture_Overflow_B:        "TX_Timestamp_Capture_Overflow_B",
+	C.TC6Regs_Event_TX_Timestamp_Capture_Overflow_C:        "TX_Timestamp_Capture_Overflow_C",
+	C.TC6Regs_Event_TX_Timestamp_Capture_Missed_A:          "TX_Timestamp_Capture_Missed_A",
+	C.TC6Regs_Event_TX_Timestamp_Capture_Missed_B:          "TX_Timestamp_Capture_Missed_B",
+	C.TC6Regs_Event_TX_Timestamp_Capture_Missed_C:          "TX_Timestamp_Capture_Missed_C",
+	C.TC6Regs_Event_MCLK_GEN_Status:                        "MCLK_GEN_Status",
+	C.TC6Regs_Event_gPTP_PA_TS_EG_Status:                   "gPTP_PA_TS_EG_Status",
+	C.TC6Regs_Event_Extended_Block_Status:                  "Extended_Block_Status",
+	C.TC6Regs_Event_SPI_Err_Int:                            "SPI_Err_Int",
+	C.TC6Regs_Event_MAC_BMGR_Int:                           "MAC_BMGR_Int",
+	C.TC6Regs_Event_MAC_Int:                                "MAC_Int",
+	C.TC6Regs_Event_HMX_Int:                                "HMX_Int",
+	C.TC6Regs_Event_GINT_Mask:                              "GINT_Mask",
+	C.TC6Regs_Event_Chip_Error:                             "Chip_Error",
+	C.TC6Regs_Event_Unsupported_Hardware:                   "Unsupported_Hardware",
+	EventInitFailed:                                        "Init_Failed",
+}
+
+func (ev Event) String() string {
+	if int(ev) < len(eventNames) {
+		return eventNames[ev]
+	}
+	return "Event(" + strconv.Itoa(int(ev)) + ")"
+}
+
+// NeedsReinit reports whether the driver reinitializes
+// the MAC-PHY in reaction to the event.
+func (ev Event) NeedsReinit() bool {
+	switch ev {
+	case C.TC6Regs_Event_Loss_of_Framing_Error,
+		C.TC6Regs_Event_RX_Non_Recoverable_Error,
+		C.TC6Regs_Event_TX_Non_Recoverable_Error:
+		return true
+	}
+	return false
+}
+
+// ProtoError is an error detected by the TC6 protocol layer
+// of the oa-tc6 library.
+type ProtoError uint8
+
+var protoErrorTexts = [...]string{
+	C.TC6Error_Succeeded:      "no error",
+	C.TC6Error_NoHardware:     "no hardware",
+	C.TC6Error_UnexpectedSv:   "unexpected start valid flag",
+	C.TC6Error_UnexpectedDvEv: "unexpected data valid or end valid flag",
+	C.TC6Error_BadChecksum:    "footer parity error",
+	C.TC6Error_UnexpectedCtrl: "unexpected control transaction",
+	C.TC6Error_BadTxData:      "header bad",
+	C.TC6Error_SyncLost:       "sync lost",
+	C.TC6Error_SpiError:       "SPI transfer failed",
+	C.TC6Error_ControlTxFail:  "control transaction failed",
+}
+
+// NeedsReinit reports whether the driver reinitializes the MAC-PHY
+// in reaction to the error. A loss of synchronization means that
+// the MAC-PHY has been reset, and has lost its configuration.
+func (e ProtoError) NeedsReinit() bool {
+	return e == C.TC6Error_SyncLost
+}
+
+func (e ProtoError) Error() string {
+	if int(e) < len(protoErrorTexts) {
+		return "tc6: " + protoErrorTexts[e]
+	}
+	return "tc6: error " + strconv.Itoa(int(e))
+}
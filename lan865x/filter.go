@@ -0,0 +1,267 @@
+package lan865x
+
+import (
+	"errors"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// Bits of the NETWORK_CONFIG register related to frame filtering.
+const (
+	netCfgCopyAllFrames   = 1 << 4
+	netCfgNoBroadcast     = 1 << 5
+	netCfgMulticastHashEn = 1 << 6
+	netCfgUnicastHashEn   = 1 << 7
+
+	netCfgFilterMask = netCfgCopyAllFrames | netCfgNoBroadcast | netCfgMulticastHashEn | netCfgUnicastHashEn
+)
+
+// NumAddrFilters is the number of specific address filters
+// that can be programmed using [Inst.SetAddrFilter] in addition
+// to the station address.
+//
+// Of the four specific address registers of the MAC, the first
+// one is used by the oa-tc6 library to derive the back off time,
+// the second one holds the station address.
+const NumAddrFilters = 2
+
+var ErrFilterIndex = errors.New("address filter index out of range")
+
+type addrFilter struct {
+	promiscuous bool
+	noBroadcast bool
+	exact       bool
+	addr        [6]byte
+	extra       [NumAddrFilters]struct {
+		addr  [6]byte
+		valid bool
+	}
+	hash      uint64
+	multicast [][6]byte
+
+	// hashOnly contains the bits set using SetHashFilter that
+	// are not due to an address in multicast. Since they do not
+	// identify an address, frames matching them bypass exact filtering.
+	hashOnly uint64
+}
+
+func (f *addrFilter) init(conf *t1s.MACConf) {
+	f.addr = conf.Addr
+	f.promiscuous = conf.CopyAllFrames
+	f.noBroadcast = conf.RejectBroadcast
+	f.exact = conf.DiscardUnmatched && !conf.AllMulticast
+	f.multicast = conf.Multicast
+	f.hash = 0
+	f.hashOnly = 0
+	if conf.AllMulticast {
+		f.hash = ^uint64(0)
+	}
+	for _, addr := range conf.Multicast {
+		f.hash |= 1 << HashIndex(addr)
+	}
+}
+
+func (f *addrFilter) netConfig() uint32 {
+	var v uint32
+	if f.promiscuous {
+		v |= netCfgCopyAllFrames
+	}
+	if f.noBroadcast {
+		v |= netCfgNoBroadcast
+	}
+	if f.hash != 0 {
+		v |= netCfgMulticastHashEn
+	}
+	return v
+}
+
+// SetPromiscuous enables or disables the reception of all frames,
+// regardless of the destination address.
+func (inst *Inst) SetPromiscuous(enable bool) error {
+	inst.filter.promiscuous = enable
+	return inst.writeNetConfig()
+}
+
+// SetMACAddr changes the station address.
+func (inst *Inst) SetMACAddr(addr [6]byte) error {
+	inst.filter.addr = addr
+	return inst.writeStationAddr()
+}
+
+// SetAddrFilter programs the specific address filter i,
+// 0 <= i < [NumAddrFilters], so that frames with a destination address
+// equal to addr, which may be a unicast or multicast address, are received.
+func (inst *Inst) SetAddrFilter(i int, addr [6]byte) error {
+	if i < 0 || i >= NumAddrFilters {
+		return ErrFilterIndex
+	}
+	f := &inst.filter.extra[i]
+	f.addr = addr
+	f.valid = true
+	return inst.writeAddrFilter(i)
+}
+
+// ClearAddrFilter disables the specific address filter i.
+func (inst *Inst) ClearAddrFilter(i int) error {
+	if i < 0 || i >= NumAddrFilters {
+		return ErrFilterIndex
+	}
+	inst.filter.extra[i].valid = false
+	return inst.writeAddrFilter(i)
+}
+
+// SetHashFilter sets the 64-bit hash filter applied to multicast
+// destination addresses. A frame is received if the bit
+// at [HashIndex] of its destination address is set.
+// A value of zero disables hash filtering.
+//
+// If [t1s.MACConf.DiscardUnmatched] is set, frames matching bits that
+// do not correspond to addresses added using [Inst.AddMulticast], or
+// configured in [t1s.MACConf.Multicast], are delivered nonetheless.
+func (inst *Inst) SetHashFilter(hash uint64) error {
+	f := &inst.filter
+	f.hashOnly = hash
+	for _, addr := range f.multicast {
+		f.hashOnly &^= 1 << HashIndex(addr)
+	}
+	return inst.setHash(hash)
+}
+
+func (inst *Inst) setHash(hash uint64) error {
+	inst.filter.hash = hash
+	err := inst.writeHash()
+	if err != nil {
+		return err
+	}
+	return inst.writeNetConfig()
+}
+
+// AddMulticast adds the multicast address addr to the hash filter,
+// and to the list of addresses accepted if [t1s.MACConf.DiscardUnmatched]
+// is set.
+func (inst *Inst) AddMulticast(addr [6]byte) error {
+	f := &inst.filter
+	f.multicast = append(f.multicast[:len(f.multicast):len(f.multicast)], addr)
+	return inst.setHash(f.hash | 1<<HashIndex(addr))
+}
+
+// SetRejectBroadcast enables or disables the rejection of broadcast frames.
+func (inst *Inst) SetRejectBroadcast(reject bool) error {
+	inst.filter.noBroadcast = reject
+	return inst.writeNetConfig()
+}
+
+// HashFilter returns the current value of the hash filter.
+func (inst *Inst) HashFilter() uint64 {
+	return inst.filter.hash
+}
+
+// HashIndex returns the index of the bit within the hash filter
+// that corresponds to addr. The index is calculated as the XOR
+// of every sixth bit of the address.
+func HashIndex(addr [6]byte) uint {
+	var idx uint
+	for i := 0; i < 48; i++ {
+		b := uint(addr[i/8]>>(i%8)) & 1
+		idx ^= b << (i % 6)
+	}
+	return idx
+}
+
+// match reports whether a frame with destination address dst
+// shall be delivered in case exact filtering is enabled.
+func (f *addrFilter) match(dst []byte) bool {
+	if f.promiscuous || !f.exact {
+		return true
+	}
+	if len(dst) < t1s.AddrLen {
+		return false
+	}
+	da := [6]byte(dst)
+	if da == f.addr {
+		return true
+	}
+	if da == t1s.BroadcastAddr {
+		return !f.noBroadcast
+	}
+	for i := range f.extra {
+		if f.extra[i].valid && f.extra[i].addr == da {
+			return true
+		}
+	}
+	for _, addr := range f.multicast {
+		if addr == da {
+			return true
+		}
+	}
+	return da[0]&1 != 0 && f.hashOnly&(1<<HashIndex(da)) != 0
+}
+
+// applyFilter writes all filter settings. It is called
+// during each initialization of the MAC-PHY.
+func (inst *Inst) applyFilter() error {
+	err := inst.writeStationAddr()
+	if err != nil {
+		return err
+	}
+	for i := range inst.filter.extra {
+		err = inst.writeAddrFilter(i)
+		if err != nil {
+			return err
+		}
+	}
+	err = inst.writeHash()
+	if err != nil {
+		return err
+	}
+	return inst.writeNetConfig()
+}
+
+func (inst *Inst) writeNetConfig() error {
+	_, err := inst.ModifyReg(tc6.RegNetworkConfig, inst.filter.netConfig(), netCfgFilterMask)
+	return err
+}
+
+func (inst *Inst) writeHash() error {
+	h := inst.filter.hash
+	err := inst.WriteReg(tc6.RegHashBottom, uint32(h))
+	if err != nil {
+		return err
+	}
+	return inst.WriteReg(tc6.RegHashTop, uint32(h>>32))
+}
+
+func (inst *Inst) writeStationAddr() error {
+	a := &inst.filter.addr
+	err := inst.writeSpecAddr(tc6.RegSpecAddr2Bot, a)
+	if err != nil {
+		return err
+	}
+	// As done by the oa-tc6 library, the lower part of the first
+	// specific address register is set to the unique lower part
+	// of the MAC address; the back off time is generated out of that.
+	v := uint32(a[5])<<24 | uint32(a[4])<<16 | uint32(a[3])<<8 | uint32(a[2])
+	return inst.WriteReg(tc6.RegSpecAddr1Bot, v)
+}
+
+func (inst *Inst) writeAddrFilter(i int) error {
+	f := &inst.filter.extra[i]
+	reg := uint32(tc6.RegSpecAddr2Bot + 2*(i+1))
+	if !f.valid {
+		// Writing the bottom register disables the filter
+		// until the top register is written.
+		return inst.WriteReg(reg, 0)
+	}
+	return inst.writeSpecAddr(reg, &f.addr)
+}
+
+func (inst *Inst) writeSpecAddr(regBot uint32, a *[6]byte) error {
+	v := uint32(a[3])<<24 | uint32(a[2])<<16 | uint32(a[1])<<8 | uint32(a[0])
+	err := inst.WriteReg(regBot, v)
+	if err != nil {
+		return err
+	}
+	v = uint32(a[5])<<8 | uint32(a[4])
+	return inst.WriteReg(regBot+1, v)
+}
@@ -20,9 +20,15 @@ extern	void	tc6regs_onEvent(TC6_t*, TC6Regs_Event_t event, void *pGlobalTag);
 
 extern	int	tc6_onSpiTransaction(uint8_t tc6instance, uint8_t *pTx, uint8_t *pRx, uint16_t len, void *pGlobalTag);
 
-extern	uint32_t	tc6regs_getTicksMs(void);
+extern	uint32_t	tc6regs_getTicksMs(void *pTag);
 
 extern	void	t1s_onRawTxPacket(void *pGlobalTag, void *pTx, uint16_t len);
+
+extern	void	t1s_onRegAccess(void *pGlobalTag, uint32_t seq, int success, uint32_t value);
+
+extern	void	t1s_onMapAccess(void *pGlobalTag, int success, uint32_t addr);
+
+extern	int	tc6regs_onInitRegs(TC6_t*, uint8_t chipRev, void *pTag);
 #endif
 
 
@@ -67,10 +73,16 @@ TC6_CB_OnSpiTransaction(uint8_t tc6instance, uint8_t *pTx, uint8_t *pRx, uint16_
 	return tc6_onSpiTransaction(tc6instance, pTx, pRx, len, pGlobalTag) != 0;
 }
 
+bool
+TC6Regs_CB_OnInitRegs(TC6_t *pInst, uint8_t chipRev, void *pTag)
+{
+	return tc6regs_onInitRegs(pInst, chipRev, pTag) != 0;
+}
+
 uint32_t
-TC6Regs_CB_GetTicksMs(void)
+TC6Regs_CB_GetTicksMs(void *pTag)
 {
-	return tc6regs_getTicksMs();
+	return tc6regs_getTicksMs(pTag);
 }
 
 
@@ -88,3 +100,49 @@ t1s_sendRawEthPacket(TC6_t *pInst, uint8_t *pTx, uint16_t len, uint8_t tsc)
 {
 	return TC6_SendRawEthernetPacket(pInst, pTx, len, tsc, onRawTx, 0);
 }
+
+
+/* Glue code for register accesses initiated from Go,
+ * calling back t1s_onRegAccess when done. The sequence number,
+ * passed as tag, allows to ignore completions of accesses
+ * that timed out.
+ */
+static void
+onRegAccess(TC6_t *pInst, bool success, uint32_t addr, uint32_t value, void *pTag, void *pGlobalTag)
+{
+	t1s_onRegAccess(pGlobalTag, (uint32_t)(uintptr_t)pTag, success, value);
+}
+
+int
+t1s_readReg(TC6_t *pInst, uint32_t addr, uint32_t seq)
+{
+	return TC6_ReadRegister(pInst, addr, true, onRegAccess, (void*)(uintptr_t)seq);
+}
+
+int
+t1s_writeReg(TC6_t *pInst, uint32_t addr, uint32_t value, uint32_t seq)
+{
+	return TC6_WriteRegister(pInst, addr, value, true, onRegAccess, (void*)(uintptr_t)seq);
+}
+
+int
+t1s_modifyReg(TC6_t *pInst, uint32_t addr, uint32_t value, uint32_t mask, uint32_t seq)
+{
+	return TC6_ReadModifyWriteRegister(pInst, addr, value, mask, true, onRegAccess, (void*)(uintptr_t)seq);
+}
+
+
+/* Glue code for applying register maps from Go,
+ * calling back t1s_onMapAccess for each entry.
+ */
+static void
+onMapAccess(TC6_t *pInst, bool success, uint32_t addr, uint32_t value, void *pTag, void *pGlobalTag)
+{
+	t1s_onMapAccess(pGlobalTag, success, addr);
+}
+
+int
+t1s_accessMap(TC6_t *pInst, const MemoryMap_t *pMap, uint16_t n)
+{
+	return TC6_MultipleRegisterAccess(pInst, pMap, n, onMapAccess, 0);
+}
@@ -5,10 +5,12 @@ package lan865x
 
 import (
 	"errors"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/knieriem/t1s"
 	"github.com/knieriem/t1s/lan865x/internal/cgo"
+	"github.com/knieriem/t1s/lan865x/tc6"
 )
 
 // #cgo CFLAGS: -Iinclude
@@ -28,7 +30,8 @@ const MTU = 1536
 // UpperProto, Dev should be populated first.
 // Then .Init may be called to initialize the driver.
 //
-// If PLCA is set to nil, then CSMA/CD is used.
+// If PLCA is set to nil, then CSMA/CD is used; otherwise
+// Init fails if the settings do not pass PLCAConf.Validate.
 type Inst struct {
 	handle unsafe.Pointer
 
@@ -38,48 +41,96 @@ type Inst struct {
 	UpperProto t1s.UpperProto
 	Dev        HwIntf
 
+	// Ticks provides the time base of the instance.
+	// If nil, the package level Ticks is used.
+	Ticks TicksProvider
+
 	tc6         *C.TC6_t
 	needService bool
 
-	pbuf      []byte
+	// RxQueueLen is the number of receive buffers; if zero,
+	// DefaultRxQueueLen is used. Received frames are queued,
+	// and delivered to UpperProto by Service, outside of
+	// the callbacks of the oa-tc6 library.
+	RxQueueLen int
+
+	// RxOverflow defines how frames are handled that
+	// are received while all buffers are in use.
+	RxOverflow RxOverflowPolicy
+
+	// RxPolicy defines which received frames are accepted.
+	RxPolicy RxPolicy
+
+	rxq       rxQueue
 	rxInvalid bool
+	rxNoBuf   bool
+
+	// TxQueueLen is the number of transmit buffers, shared by
+	// all transmit classes; if zero, DefaultTxQueueLen is used.
+	// Service polls the upper layer until all buffers are in use,
+	// and transmits queued frames as scheduled by TxSched.
+	TxQueueLen int
+
+	// TxSched configures the scheduling of the transmit classes.
+	TxSched TxSched
+
+	txq txQueue
 
-	txBuf     []byte
-	txBufBusy bool
+	// InitProfiles contain register settings applied at each
+	// initialization of the MAC-PHY, including reinitializations,
+	// after the default configuration of the oa-tc6 library and the
+	// address filters, before the MAC is enabled. The profiles
+	// matching the chip's silicon revision are applied in order.
+	InitProfiles []InitProfile
+
+	mapAccess   mapAccess
+	initErr     error
+	initialized bool // Init succeeded
+	attached    bool // see Attach
 
 	spiTag uint8
 
+	reg      regAccess
+	chip     ChipInfo
+	filter   addrFilter
+	counters counters
+
+	// OnEvent and OnProtoError, if set, are called for each
+	// event reported by the oa-tc6 library, resp. each protocol error.
+	// OnEvent also receives EventInitFailed, if a reinitialization
+	// of the MAC-PHY after Init, or after a wake-up, fails; it is
+	// retried by the driver.
+	OnEvent      func(ev Event)
+	OnProtoError func(err ProtoError)
+
+	// OnWake, if set, is called once the MAC-PHY, after having
+	// been put to sleep using [Inst.Sleep], has been woken up
+	// and initialized again.
+	OnWake func(src WakeSource)
+
+	power        atomic.Uint32 // PowerState
+	wakeSrc      WakeSource
+	wakeDeadline uint32
+
+	// Log, if set, receives the driver's log messages:
+	// errors, events at info level, frames at debug level, and
+	// slices of received frames at [LevelTrace].
+	// If Name is not empty, messages carry an attribute [KeyInst].
+	Log    Logger
+	Name   string
+	logger Logger
+
 	// DebugInfo and DebugError can be set to functions
 	// logging at info resp. error level.
-	// This way a direct dependency on a [slog.Logger] can be
-	// avoided for now, which may increase chances for tinygo
-	// to better optimize for size in case logging is disabled.
+	//
+	// Deprecated: Use Log. DebugInfo and DebugError are only used
+	// if Log is nil; DebugInfo then also receives debug messages.
 	DebugInfo  func(msg string, a ...any)
 	DebugError func(msg string, a ...any)
 }
 
-func (inst *Inst) info(msg string, a ...any) {
-	if inst.DebugInfo == nil {
-		return
-	}
-	inst.DebugInfo(msg, a...)
-}
-
-func (inst *Inst) logError(msg string, a ...any) {
-	if inst.DebugError == nil {
-		return
-	}
-	inst.DebugError(msg, a...)
-}
-
-var pbuf [MTU]byte
-var txbuf [MTU]byte
-
-type HwIntf interface {
-	Reset() error
-	IntrActive() bool
-	SpiTxRx(tx, rx []byte, done func(err error)) error
-}
+// HwIntf defines the hardware interface to a LAN865x.
+type HwIntf = tc6.HwIntf
 
 func cBool(v bool) C.int {
 	if v {
@@ -90,16 +141,31 @@ func cBool(v bool) C.int {
 
 var nullPLCAConf t1s.PLCAConf
 
-func (inst *Inst) Init() bool {
-	inst.txBuf = txbuf[:]
-	inst.pbuf = pbuf[:0]
-	h := cgo.NewHandle(inst)
-	inst.handle = unsafe.Pointer(&h)
-	p := C.TC6_Init(inst.handle)
-	if p == nil {
-		return false
+// Init initializes the driver and the MAC-PHY. If the MAC-PHY
+// is not supported, an [*UnsupportedChipError] is returned.
+// If Init fails, the oa-tc6 library instance is released.
+func (inst *Inst) Init() (err error) {
+	inst.initLog()
+	inst.initErr = nil
+	if inst.ticks() == nil {
+		inst.log(LevelError, "init: no ticks provider")
+		return ErrNoTicks
+	}
+	if inst.PLCA != nil {
+		if err := inst.PLCA.Validate(); err != nil {
+			inst.log(LevelError, "init", Attr{KeyErr, err})
+			return err
+		}
+	}
+	inst.txq.init(inst.TxQueueLen, &inst.TxSched)
+	inst.rxq.init(inst.RxQueueLen)
+	if err := inst.newTC6(); err != nil {
+		return err
 	}
+	defer inst.closeOnErr(&err)
+	p := inst.tc6
 	mac := inst.MAC
+	inst.filter.init(mac)
 	enablePLCA := true
 	plca := inst.PLCA
 	if plca == nil {
@@ -112,13 +178,95 @@ func (inst *Inst) Init() bool {
 		C.uint8_t(plca.BurstCount), C.uint8_t(plca.BurstTimer),
 		cBool(mac.CopyAllFrames), cBool(mac.TxCutThrough), cBool(mac.RxCutThrough))
 	if ret == 0 {
-		return false
+		err := inst.initError()
+		inst.log(LevelError, "init", Attr{KeyErr, err})
+		return err
 	}
 	for C.TC6Regs_GetInitDone(p) == 0 {
 		C.TC6_Service(p, cBool(true))
 	}
+	c, err := inst.readChipInfo()
+	if err != nil {
+		return err
+	}
+	if !c.Supported() {
+		err := &UnsupportedChipError{Info: c}
+		inst.log(LevelError, "init", Attr{KeyErr, err})
+		return err
+	}
+	inst.chip = c
+	inst.initialized = true
+	inst.log(LevelInfo, "init", Attr{"chip", c.String()}, Attr{"tc6", c.TC6VersionString()})
+	return nil
+}
+
+// Attach prepares the driver for accessing the registers of a MAC-PHY
+// that has already been initialized, by another program, or a previous
+// run of this one. Unlike [Inst.Init], it neither resets the MAC-PHY nor
+// changes its configuration, so that the link stays up. Only register
+// accesses and [Inst.ChipInfo] are supported after Attach; Service must
+// not be called. Since accesses are protected, Attach fails on a MAC-PHY
+// that has not been initialized.
+func (inst *Inst) Attach() (err error) {
+	inst.initLog()
+	if inst.ticks() == nil {
+		inst.log(LevelError, "attach: no ticks provider")
+		return ErrNoTicks
+	}
+	if err := inst.newTC6(); err != nil {
+		return err
+	}
+	defer inst.closeOnErr(&err)
+	inst.attached = true
+	c, err := inst.readChipInfo()
+	if err != nil {
+		return err
+	}
+	if !c.Supported() {
+		return &UnsupportedChipError{Info: c}
+	}
+	inst.chip = c
+	return nil
+}
+
+// newTC6 allocates an instance of the oa-tc6 library.
+func (inst *Inst) newTC6() error {
+	h := cgo.NewHandle(inst)
+	p := C.TC6_Init(unsafe.Pointer(&h))
+	if p == nil {
+		h.Delete()
+		return ErrNoInstance
+	}
+	inst.handle = unsafe.Pointer(&h)
 	inst.tc6 = p
-	return true
+	return nil
+}
+
+// closeOnErr releases the oa-tc6 library instance if *err is not nil,
+// so that a failed Init or Attach does not keep the only instance.
+func (inst *Inst) closeOnErr(err *error) {
+	if *err != nil {
+		inst.Close()
+	}
+}
+
+// Close releases the oa-tc6 library instance, so that another
+// Inst may be initialized. Frames not yet transmitted are dropped.
+// After Close, inst must not be used, unless it is initialized again.
+func (inst *Inst) Close() {
+	if inst.tc6 == nil {
+		return
+	}
+	if !inst.attached {
+		// Make the register layer start from scratch on the next Init.
+		C.TC6Regs_Reinit(inst.tc6)
+	}
+	inst.attached = false
+	inst.initialized = false
+	C.TC6_Destroy(inst.tc6)
+	inst.tc6 = nil
+	(*cgo.Handle)(inst.handle).Delete()
+	inst.handle = nil
 }
 
 func instFromHandle(context unsafe.Pointer) *Inst {
@@ -128,6 +276,10 @@ func instFromHandle(context unsafe.Pointer) *Inst {
 
 func (inst *Inst) Service() (allDone bool) {
 	allDone = true
+	if inst.servicePower() {
+		inst.deliverRx()
+		return allDone
+	}
 
 	intrTriggered := inst.Dev.IntrActive()
 	if intrTriggered || inst.needService {
@@ -136,16 +288,23 @@ func (inst *Inst) Service() (allDone bool) {
 			intrTriggered = false
 		}
 	}
+	inst.deliverRx()
 
-	if !inst.txBufBusy {
-		// Check for ethernet frames to be sent down.
-		nTx, err := inst.UpperProto.PollForEth(inst.txBuf)
-		if err != nil {
-		} else if nTx != 0 {
-			// After SendEthDown txBufBusy will be set.
-			// It is reset later by a callback when
-			// transmission finished.
-			inst.SendEthDown(inst.txBuf[:nTx])
+	// Check for ethernet frames to be sent down.
+	if inst.pollTx() {
+		allDone = false
+	}
+	q := &inst.txq
+	if q.busy == -1 {
+		if f, ok := q.pop(); ok {
+			// The library keeps a reference to the buffer, so it is
+			// marked busy until a callback reports that transmission
+			// finished, which may happen already within SendEthDown.
+			q.busy = f.buf
+			if inst.SendEthDown(q.bufs[f.buf][:f.n]) != nil {
+				q.busy = -1
+				q.release(f.buf)
+			}
 			allDone = false
 		}
 	}
@@ -153,17 +312,10 @@ func (inst *Inst) Service() (allDone bool) {
 	return allDone
 }
 
-// Ticks must be set to an actual implementation of [TicksProvider]
-// before a driver can be initialized.
-var Ticks TicksProvider
-
-type TicksProvider interface {
-	Milliseconds() uint32
-}
-
 func (inst *Inst) SendEthDown(packet []byte) error {
 	ret := C.t1s_sendRawEthPacket(inst.tc6, (*C.uint8_t)(&packet[0]), C.uint16_t(len(packet)), 0)
-	if ret != 0 {
+	if ret == 0 {
+		inst.counters.txErrors.Add(1)
 		return ErrSendFailure
 	}
 	return nil
@@ -172,11 +324,23 @@ func (inst *Inst) SendEthDown(packet []byte) error {
 //export t1s_onRawTxPacket
 func t1s_onRawTxPacket(gTag, pTx unsafe.Pointer, nTx uint16) {
 	inst := instFromHandle(gTag)
-	inst.txBufBusy = false
-	inst.DebugInfo("onTxPacket", "len", nTx)
+	q := &inst.txq
+	if i := q.busy; i != -1 && pTx == unsafe.Pointer(&q.bufs[i][0]) {
+		q.busy = -1
+		q.release(i)
+	}
+	inst.counters.txFrames.Add(1)
+	inst.counters.txBytes.Add(uint64(nTx))
+	inst.logLen(LevelDebug, "onTxPacket", int(nTx))
 }
 
 func (inst *Inst) SetPLCA(enable bool, nodeId uint8, nodeCount uint8) error {
+	if enable {
+		c := t1s.PLCAConf{NodeID: nodeId, NodeCount: nodeCount}
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
 	ret := C.TC6Regs_SetPlca(inst.tc6, cBool(enable), C.uint8_t(nodeId), C.uint8_t(nodeCount))
 	if ret == 0 {
 		return ErrRegsFailure
@@ -187,87 +351,116 @@ func (inst *Inst) SetPLCA(enable bool, nodeId uint8, nodeCount uint8) error {
 //export tc6_onRxEthernetSlice
 func tc6_onRxEthernetSlice(_ *C.TC6_t, pRx unsafe.Pointer, offset uint16, nRx uint16, gTag unsafe.Pointer) {
 	inst := instFromHandle(gTag)
-	//inst.info("onRxSlice", "offset", offset, "numRx", nRx, "rxInvalid", inst.rxInvalid)
-	if inst.rxInvalid {
-		return
+	if inst.logEnabled(LevelTrace) {
+		inst.log(LevelTrace, "onRxSlice", Attr{"offset", int(offset)}, Attr{KeyLen, int(nRx)}, Attr{"rxInvalid", inst.rxInvalid})
 	}
-	newLen := int(offset + nRx)
-	if newLen > cap(inst.pbuf) {
-		inst.rxInvalid = true
+	if inst.rxInvalid {
 		return
 	}
-	if offset != 0 {
-		if len(inst.pbuf) == 0 {
+	q := &inst.rxq
+	if offset == 0 {
+		if q.cur != nil {
 			inst.rxInvalid = true
 			return
 		}
-	} else if len(inst.pbuf) != 0 {
+		b, dropped := q.get(inst.RxOverflow)
+		if dropped {
+			inst.counters.rxOverflows.Add(1)
+		}
+		if b == nil {
+			inst.rxInvalid = true
+			inst.rxNoBuf = true
+			return
+		}
+		q.cur = b
+	} else if q.cur == nil || len(q.cur.buf) == 0 {
+		inst.rxInvalid = true
+		return
+	}
+	b := q.cur
+	newLen := int(offset + nRx)
+	if newLen > cap(b.buf) {
 		inst.rxInvalid = true
 		return
 	}
 
 	rx := unsafe.Slice((*byte)(pRx), nRx)
-	inst.pbuf = inst.pbuf[:newLen]
-	copy(inst.pbuf[offset:], rx)
+	b.buf = b.buf[:newLen]
+	copy(b.buf[offset:], rx)
 }
 
-const (
-	// IEEE 802.3 Ethernet Frame Format
-	ethPreambleSize       = 7
-	ethStartFrameDelSize  = 1
-	ethPreambleAndSFDSize = ethPreambleSize + ethStartFrameDelSize
-
-	ethMACDestSize  = 6
-	ethMACSrcSize   = 6
-	eth8021QtagSize = 4 // optional
-	ethLengthSize   = 2
-
-	ethHeaderMinSize = ethMACDestSize + ethMACSrcSize + ethLengthSize
-	ipHeaderMinSize  = 20
-	udpHeaderMinSize = 8
-
-	ethFCSsize = 4
-
-	// minPacketHeaderSize equals a value of 42
-	// See also https://github.com/MicrochipTech/oa-tc6-lib/issues/17#issuecomment-2089815956
-	// Note that up to now with packets provided by [onRxEthernetPacket]
-	// we didn't observe a packetLen smaller than 64 bytes.
-	minPacketHeaderSize = ethHeaderMinSize + ipHeaderMinSize + udpHeaderMinSize
-)
-
 //export tc6_onRxEthernetPacket
 func tc6_onRxEthernetPacket(_ *C.TC6_t, success int, packetLen uint16, rxTimestamp *uint64, gTag unsafe.Pointer) {
 	inst := instFromHandle(gTag)
 
-	pbuf := inst.pbuf
-	inst.pbuf = pbuf[:0]
-	rxInvalid := inst.rxInvalid
-	inst.rxInvalid = false
+	q := &inst.rxq
+	b := q.cur
+	q.cur = nil
+	rxInvalid, rxNoBuf := inst.rxInvalid, inst.rxNoBuf
+	inst.rxInvalid, inst.rxNoBuf = false, false
+	var pbuf []byte
+	if b != nil {
+		pbuf = b.buf
+	}
 
-	var status string
+	var err error
 	switch {
+	case rxNoBuf:
+		inst.counters.rxOverflows.Add(1)
+		inst.logLen(LevelDebug, "onRxPacket: no buffer available", int(packetLen))
+		return
 	case success == 0 || rxInvalid || len(pbuf) == 0:
-		status = "invalid state"
+		err = ErrRxInvalid
 	case len(pbuf) != int(packetLen):
-		status = "invalid length"
-	case packetLen < minPacketHeaderSize:
-		status = "too short"
+		err = ErrRxLength
+	default:
+		err = inst.RxPolicy.check(pbuf)
 	}
-	if len(status) != 0 {
-		inst.logError("onRxPacket: packet dropped", "len", packetLen, "err", status)
+	if err != nil {
+		inst.counters.rxErrors.Add(1)
+		_, owns := inst.UpperProto.(RxBufReceiver)
+		if !inst.RxPolicy.DeliverErrors || !owns || len(pbuf) == 0 {
+			if b != nil {
+				q.put(b)
+			}
+			inst.log(LevelError, "onRxPacket: packet dropped", Attr{KeyLen, int(packetLen)}, Attr{KeyErr, err})
+			return
+		}
+		if inst.logEnabled(LevelDebug) {
+			inst.log(LevelDebug, "onRxPacket: delivering flagged packet", Attr{KeyLen, int(packetLen)}, Attr{KeyErr, err})
+		}
+	} else if !inst.filter.match(pbuf) {
+		q.put(b)
+		inst.counters.rxFiltered.Add(1)
+		inst.logLen(LevelDebug, "onRxPacket: no filter match", int(packetLen))
 		return
+	} else {
+		inst.logLen(LevelDebug, "onRxPacket", int(packetLen))
 	}
-	inst.info("onRxPacket", "len", packetLen)
-	err := inst.UpperProto.SendEthUp(pbuf)
-	if err != nil {
-		inst.logError("onRxPacket: sendEthUp failed", "err", err)
+	if inst.RxPolicy.StripFCS && len(b.buf) >= t1s.FCSLen {
+		b.buf = b.buf[:len(b.buf)-t1s.FCSLen]
 	}
+	b.err = err
+	inst.counters.rxFrames.Add(1)
+	inst.counters.rxBytes.Add(uint64(len(b.buf)))
+	q.enqueue(b)
 }
 
 //export tc6_onError
 func tc6_onError(_ *C.TC6_t, e C.TC6_Error_t, gTag unsafe.Pointer) {
 	inst := instFromHandle(gTag)
-	inst.logError("onError", "err", e)
+	err := ProtoError(e)
+	if int(err) < NumProtoErrors {
+		inst.counters.protoErrors[err].Add(1)
+	}
+	reinit := err.NeedsReinit() && C.TC6Regs_GetInitDone(inst.tc6) != 0
+	if reinit {
+		inst.reinit()
+	}
+	inst.log(LevelError, "onError", Attr{KeyErr, err}, Attr{KeyCode, int(err)}, Attr{KeyReinit, reinit})
+	if inst.OnProtoError != nil {
+		inst.OnProtoError(err)
+	}
 }
 
 //export tc6_onNeedService
@@ -282,24 +475,50 @@ var ErrRegsFailure = errors.New("tc6regs call failed")
 //export tc6regs_onEvent
 func tc6regs_onEvent(_ *C.TC6_t, event C.TC6Regs_Event_t, pTag unsafe.Pointer) {
 	inst := instFromHandle(pTag)
-	ev := regsEvent(event)
-	reinit := ev.needsReinit()
+	inst.onEvent(Event(event))
+}
+
+func (inst *Inst) onEvent(ev Event) {
+	reinit := ev.NeedsReinit()
+	c := &inst.counters
+	if int(ev) < NumEvents {
+		c.events[ev].Add(1)
+	}
 	if reinit {
-		C.TC6Regs_Reinit(inst.tc6)
+		inst.reinit()
+	}
+	inst.log(LevelInfo, "onEvent", Attr{KeyEvent, ev}, Attr{KeyCode, int(ev)}, Attr{KeyReinit, reinit})
+	if inst.OnEvent != nil {
+		inst.OnEvent(ev)
 	}
-	inst.info("onEvent", "code", ev, "reinit", reinit)
 }
 
-type regsEvent uint8
+// reinit makes the register layer of the
+// oa-tc6 library initialize the MAC-PHY again.
+func (inst *Inst) reinit() {
+	c := &inst.counters
+	c.reinits.Add(1)
+	c.status.Store(c.status.Load() &^ stLinkUp)
+	C.TC6Regs_Reinit(inst.tc6)
+}
 
-func (ev regsEvent) needsReinit() bool {
-	switch ev {
-	case C.TC6Regs_Event_Loss_of_Framing_Error,
-		C.TC6Regs_Event_RX_Non_Recoverable_Error,
-		C.TC6Regs_Event_TX_Non_Recoverable_Error:
-		return true
+//export tc6regs_onInitRegs
+func tc6regs_onInitRegs(_ *C.TC6_t, chipRev uint8, pTag unsafe.Pointer) C.int {
+	inst := instFromHandle(pTag)
+	inst.initErr = nil
+	err := inst.applyFilter()
+	if err == nil {
+		err = inst.applyProfiles(chipRev)
+	}
+	if err != nil {
+		inst.initErr = err
+		inst.log(LevelError, "onInitRegs", Attr{KeyErr, err})
+		if inst.initialized {
+			// Init is not there to return the error.
+			inst.onEvent(EventInitFailed)
+		}
 	}
-	return false
+	return cBool(err == nil)
 }
 
 //export tc6_onSpiTransaction
@@ -317,6 +536,7 @@ func (inst *Inst) spiDone(err error) {
 }
 
 //export tc6regs_getTicksMs
-func tc6regs_getTicksMs() uint32 {
-	return Ticks.Milliseconds()
+func tc6regs_getTicksMs(pTag unsafe.Pointer) uint32 {
+	inst := instFromHandle(pTag)
+	return inst.ticks().Milliseconds()
 }
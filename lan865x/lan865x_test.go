@@ -0,0 +1,250 @@
+package lan865x_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/emu"
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+var (
+	dutAddr  = [6]byte{0x02, 0, 0, 0, 0, 1}
+	peerAddr = [6]byte{0x02, 0, 0, 0, 0, 2}
+)
+
+// upper transmits the frames in tx, one per poll,
+// and collects received frames.
+type upper struct {
+	tx [][]byte
+	rx [][]byte
+}
+
+func (u *upper) SendEthUp(pkt []byte) error {
+	u.rx = append(u.rx, append([]byte(nil), pkt...))
+	return nil
+}
+
+func (u *upper) PollForEth(buf []byte) (int, error) {
+	if len(u.tx) == 0 {
+		return 0, nil
+	}
+	n := copy(buf, u.tx[0])
+	u.tx = u.tx[1:]
+	return n, nil
+}
+
+func frame(dst, src [6]byte, payload string) []byte {
+	f := make([]byte, 60)
+	copy(f, dst[:])
+	copy(f[6:], src[:])
+	f[12], f[13] = 0x88, 0xB5
+	copy(f[14:], payload)
+	return f
+}
+
+func newInst(t *testing.T, bus *bussim.Bus, dev lan865x.HwIntf) (*lan865x.Inst, *upper) {
+	up := new(upper)
+	inst := &lan865x.Inst{
+		MAC:        &t1s.MACConf{Addr: dutAddr},
+		UpperProto: up,
+		Dev:        dev,
+		Ticks:      bus,
+		RxPolicy:   lan865x.RxPolicy{CheckFCS: true, StripFCS: true},
+	}
+	t.Cleanup(inst.Close)
+	return inst, up
+}
+
+// service runs inst for d, advancing bus by a millisecond each step.
+func service(inst *lan865x.Inst, bus *bussim.Bus, d time.Duration) {
+	for end := bus.Now() + d; bus.Now() < end; {
+		inst.Service()
+		bus.Advance(time.Millisecond)
+	}
+}
+
+func TestInitUnsupported(t *testing.T) {
+	bus := bussim.New(1)
+	bad := emu.New(bus, "bad", &emu.Conf{Model: 0x8652})
+	inst, _ := newInst(t, bus, bad)
+	var uerr *lan865x.UnsupportedChipError
+	if err := inst.Init(); !errors.As(err, &uerr) {
+		t.Fatalf("Init: %v; want UnsupportedChipError", err)
+	}
+	if uerr.Info.Model != 0x8652 {
+		t.Errorf("model %#x, want 0x8652", uerr.Info.Model)
+	}
+	if err := inst.Attach(); !errors.As(err, &uerr) {
+		t.Fatalf("Attach: %v; want UnsupportedChipError", err)
+	}
+
+	// The failed calls must have released the oa-tc6 instance.
+	good := emu.New(bus, "good", &emu.Conf{})
+	inst2, _ := newInst(t, bus, good)
+	if err := inst2.Init(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// badHeader corrupts the parity of the header of
+// the next data transfer, if armed.
+type badHeader struct {
+	lan865x.HwIntf
+	armed bool
+}
+
+func (d *badHeader) SpiTxRx(tx, rx []byte, done func(err error)) error {
+	if d.armed && tc6.DataHeader(tc6.Word(tx)).DNC() {
+		d.armed = false
+		tx = append([]byte(nil), tx...)
+		tx[3] ^= 1
+	}
+	return d.HwIntf.SpiTxRx(tx, rx, done)
+}
+
+func TestExtStatusUnlock(t *testing.T) {
+	bus := bussim.New(1)
+	dev := &badHeader{HwIntf: emu.New(bus, "dut", &emu.Conf{})}
+	inst, up := newInst(t, bus, dev)
+	clock := new(lan865x.VirtualClock)
+	inst.Ticks = clock
+	var events []string
+	inst.OnEvent = func(ev lan865x.Event) {
+		events = append(events, ev.String())
+	}
+	if err := inst.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// Reset_Complete, reported during initialization,
+	// locks extended status reporting, too.
+	service(inst, bus, 50*time.Millisecond)
+	clock.Advance(100 * time.Millisecond)
+	service(inst, bus, 10*time.Millisecond)
+	events = nil
+
+	// send makes the driver transmit a frame, using a corrupted header.
+	send := func() {
+		dev.armed = true
+		up.tx = append(up.tx, frame(peerAddr, dutAddr, "x"))
+		service(inst, bus, 10*time.Millisecond)
+	}
+	send()
+	if len(events) != 1 || events[0] != "Header_Error" {
+		t.Fatalf("events %v, want [Header_Error]", events)
+	}
+
+	// Extended status stays locked for DELAY_UNLOCK_EXT (100 ms)
+	// of the virtual clock, regardless of the time passing on the bus.
+	clock.Advance(99 * time.Millisecond)
+	send()
+	service(inst, bus, 200*time.Millisecond)
+	if len(events) != 1 {
+		t.Fatalf("events %v while extended status is locked", events)
+	}
+	clock.Advance(time.Millisecond)
+	send()
+	if len(events) != 2 || events[1] != "Header_Error" {
+		t.Fatalf("events %v after unlock, want a second Header_Error", events)
+	}
+}
+
+// group returns the n-th multicast address whose hash index is idx.
+func group(idx uint, n int) [6]byte {
+	for i := 0; ; i++ {
+		a := [6]byte{0x01, 0x00, 0x5e, 0, byte(i >> 8), byte(i)}
+		if lan865x.HashIndex(a) != idx {
+			continue
+		}
+		if n == 0 {
+			return a
+		}
+		n--
+	}
+}
+
+func TestHashFilterDiscardUnmatched(t *testing.T) {
+	bus := bussim.New(1)
+	inst, up := newInst(t, bus, emu.New(bus, "dut", &emu.Conf{}))
+	listed := group(1, 0)
+	inst.MAC.Multicast = [][6]byte{listed}
+	inst.MAC.DiscardUnmatched = true
+	if err := inst.Init(); err != nil {
+		t.Fatal(err)
+	}
+	service(inst, bus, 50*time.Millisecond)
+	if err := inst.SetHashFilter(inst.HashFilter() | 1<<5); err != nil {
+		t.Fatal(err)
+	}
+	peer := bus.Attach("peer", nil)
+	for _, dst := range [][6]byte{
+		listed,
+		group(5, 0), // accepted by the hash filter
+		group(9, 0), // rejected by the MAC
+		group(1, 1), // not listed, but having the same hash index
+	} {
+		peer.Send(frame(dst, peerAddr, "x"))
+	}
+	service(inst, bus, 50*time.Millisecond)
+
+	var got [][6]byte
+	for _, f := range up.rx {
+		got = append(got, [6]byte(f))
+	}
+	if want := [][6]byte{listed, group(5, 0)}; !slices.Equal(got, want) {
+		t.Errorf("received frames for %x, want %x", got, want)
+	}
+}
+
+func TestWakeFailed(t *testing.T) {
+	bus := bussim.New(1)
+	m := emu.New(bus, "dut", &emu.Conf{})
+	inst, _ := newInst(t, bus, m)
+	var events []string
+	inst.OnEvent = func(ev lan865x.Event) {
+		events = append(events, ev.String())
+	}
+	var woke []lan865x.WakeSource
+	inst.OnWake = func(src lan865x.WakeSource) {
+		woke = append(woke, src)
+	}
+	if err := inst.Init(); err != nil {
+		t.Fatal(err)
+	}
+	service(inst, bus, 50*time.Millisecond)
+
+	// Without wake-up by WAKE_IN enabled, Wake has no effect
+	// on the MAC-PHY, which is then not initialized again.
+	if err := inst.Sleep(&lan865x.SleepConf{}); err != nil {
+		t.Fatal(err)
+	}
+	events = nil
+	if err := inst.Wake(); err != nil {
+		t.Fatal(err)
+	}
+	service(inst, bus, 1100*time.Millisecond)
+	if s := inst.PowerState(); s != lan865x.PowerWakeFailed {
+		t.Fatalf("power state %v, want %v", s, lan865x.PowerWakeFailed)
+	}
+	if !slices.Contains(events, "Init_Failed") {
+		t.Errorf("events %v, want Init_Failed", events)
+	}
+	if len(woke) != 0 {
+		t.Errorf("OnWake called")
+	}
+
+	// Initialization is retried.
+	m.Reset()
+	service(inst, bus, 100*time.Millisecond)
+	if s := inst.PowerState(); s != lan865x.PowerOn {
+		t.Fatalf("power state %v after reset, want %v", s, lan865x.PowerOn)
+	}
+	if len(woke) != 1 || woke[0] != lan865x.WakeHost {
+		t.Errorf("OnWake calls %v, want [host]", woke)
+	}
+}
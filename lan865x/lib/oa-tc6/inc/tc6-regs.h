@@ -146,9 +146,10 @@ void TC6_CB_OnExtendedStatus(TC6_t *pInst, void *pGlobalTag);
 /**
  * \brief Callback when ever this component needs to get the current tick count in Milliseconds
  * \note This function must be implemented by the integrator.
+ * \param pTag - The exact same pointer, which was given along with the TC6Regs_Init() function.
  * \return Integrator need to return the current tick count.
  */
-uint32_t TC6Regs_CB_GetTicksMs(void);
+uint32_t TC6Regs_CB_GetTicksMs(void *pTag);
 
 /**
  * \brief Callback when ever an GMAC/PHY event occured
@@ -159,4 +160,15 @@ uint32_t TC6Regs_CB_GetTicksMs(void);
  */
  void TC6Regs_CB_OnEvent(TC6_t *pInst, TC6Regs_Event_t event, void *pTag);
 
+/**
+ * \brief Callback after the register settings have been deployed, right before MAC transmitter and receiver get enabled
+ * \note This function must be implemented by the integrator. It is called again on every reinitialization.
+ * \note It is safe inside this callback to access registers via TC6_ReadRegister(), TC6_WriteRegister() and TC6_Service().
+ * \param pInst - The pointer returned by TC6_Init.
+ * \param chipRev - The LAN865x Revision number.
+ * \param pTag - The exact same pointer, which was given along with the TC6Regs_Init() function.
+ * \return true, if the initialization may continue. false, initialization failed.
+ */
+bool TC6Regs_CB_OnInitRegs(TC6_t *pInst, uint8_t chipRev, void *pTag);
+
 #endif /* TC6_REGS_H_ */
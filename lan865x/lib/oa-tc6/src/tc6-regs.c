@@ -63,6 +63,7 @@ typedef struct
     uint8_t burstTimer;
     uint8_t chipRev;
     bool extBlock;
+    bool extLocked;
     bool initialized;
     bool initDone;
     bool enablePlca;
@@ -127,7 +128,8 @@ void TC6Regs_CheckTimers(void)
     /* Find existing entry */
     for (i = 0u; i < TC6_MAX_INSTANCES; i++) {
         TC6Reg_t *pReg = &m_reg[i];
-        if ((0u != pReg->unlockExtTime) && ((TC6Regs_CB_GetTicksMs() - pReg->unlockExtTime) >= DELAY_UNLOCK_EXT)) {
+        if (pReg->extLocked && ((TC6Regs_CB_GetTicksMs(pReg->pTag) - pReg->unlockExtTime) >= DELAY_UNLOCK_EXT)) {
+            pReg->extLocked = false;
             pReg->unlockExtTime = 0;
             TC6_UnlockExtendedStatus(pReg->pTC6);
         }
@@ -187,7 +189,8 @@ void TC6_CB_OnExtendedStatus(TC6_t *pInst, void *pGlobalTag)
 {
    (void)pGlobalTag;
     TC6Reg_t *pReg = GetContext(pInst);
-    pReg->unlockExtTime = TC6Regs_CB_GetTicksMs();
+    pReg->unlockExtTime = TC6Regs_CB_GetTicksMs(pReg->pTag);
+    pReg->extLocked = true;
     while (!TC6_ReadRegister(pInst, 0x00000008, CONTROL_PROTECTION, OnStatus0, NULL)) {
         TC6_Service(pInst, true);
     }
@@ -227,7 +230,7 @@ static void DoInitialization(TC6Reg_t *pReg)
     /*>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>*/
 
     static const MemoryMap_t TC6_MEMMAP[] = {
-        {  .address=0x00000004,  .value=0x00000026,  .mask=0x00000000,  .op=MemOp_Write,  .secure=false }, /* CONFIG0 */
+        {  .address=0x00000004,  .value=(0x00000020 | TC6_CONFIG0_CPS),  .mask=0x00000000,  .op=MemOp_Write,  .secure=false }, /* CONFIG0 */
         {  .address=0x00010000,  .value=0x00000000,  .mask=0x00000000,  .op=MemOp_Write,  .secure=true  }, /* NETWORK_CONTROL */
         {  .address=0x00040091,  .value=0x00009660,  .mask=0x00000000,  .op=MemOp_Write,  .secure=true  },
         {  .address=0x00040081,  .value=0x00000080,  .mask=0x00000000,  .op=MemOp_Write,  .secure=true  },
@@ -327,7 +330,7 @@ static void DoInitialization(TC6Reg_t *pReg)
         HandlePlca(pReg);
 
         /* Cut Through / Store and Forward mode */
-        regVal = 0x9026;
+        regVal = 0x9020u | TC6_CONFIG0_CPS;
         if (pReg->txCutThrough) {
             regVal |= 0x200u;
         }
@@ -337,6 +340,10 @@ static void DoInitialization(TC6Reg_t *pReg)
         while (pReg->initialized && !TC6_WriteRegister(pReg->pTC6, 0x00000004 /* CONFIG0 */, regVal, CONTROL_PROTECTION, OnInitialRegCB, NULL)) {
             TC6_Service(pReg->pTC6, true);
         }
+        /* Integrator specific settings */
+        if (pReg->initialized && !TC6Regs_CB_OnInitRegs(pReg->pTC6, pReg->chipRev, pReg->pTag)) {
+            pReg->initialized = false;
+        }
         while (pReg->initialized && !TC6_WriteRegister(pReg->pTC6, 0x00010000 /* NETWORK_CONTROL */, 0xCu, CONTROL_PROTECTION, OnInitDone, NULL)) {
             TC6_Service(pReg->pTC6, true);
         }
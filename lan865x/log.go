@@ -0,0 +1,107 @@
+package lan865x
+
+// Level is the severity of a log message. The values
+// match those of the corresponding levels of package log/slog.
+type Level int
+
+const (
+	// LevelTrace is the level of messages about individual
+	// slices of received frames; it equals spitrace.LevelTrace.
+	LevelTrace Level = -8
+
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelError Level = 8
+)
+
+// Logger receives the log messages of the driver. Enabled is
+// called before a message and its attributes are created, so that
+// disabled levels cost little more than the call. The values of
+// attributes are of type int, bool, string, or error, or, for
+// [KeyEvent] and [KeyCode], an [Event] or a [ProtoError].
+//
+// Package lan865x/slogger provides a Logger based on a [log/slog] Handler.
+// Since lan865x itself does not depend on log/slog, programs not
+// using slog, like small TinyGo builds, do not need to include it.
+type Logger interface {
+	Enabled(level Level) bool
+	Log(level Level, msg string, attrs ...Attr)
+}
+
+// Attr is a key-value pair attached to a log message.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// Keys of attributes of log messages created by the driver.
+const (
+	KeyInst   = "inst"   // Inst.Name
+	KeyEvent  = "event"  // an Event
+	KeyCode   = "code"   // numeric value of an Event or ProtoError
+	KeyErr    = "err"    // an error
+	KeyLen    = "len"    // length of a frame or slice
+	KeyReinit = "reinit" // whether an event or error triggers a reinitialization
+)
+
+func (inst *Inst) initLog() {
+	inst.logger = inst.Log
+	if inst.logger == nil && (inst.DebugInfo != nil || inst.DebugError != nil) {
+		inst.logger = debugFuncs{info: inst.DebugInfo, err: inst.DebugError}
+	}
+}
+
+func (inst *Inst) logEnabled(level Level) bool {
+	return inst.logger != nil && inst.logger.Enabled(level)
+}
+
+// log passes a message to the Logger. Since the attributes are
+// created before the call, frequent messages should be guarded
+// by a check of logEnabled.
+func (inst *Inst) log(level Level, msg string, attrs ...Attr) {
+	if !inst.logEnabled(level) {
+		return
+	}
+	if inst.Name != "" {
+		attrs = append([]Attr{{KeyInst, inst.Name}}, attrs...)
+	}
+	inst.logger.Log(level, msg, attrs...)
+}
+
+// logLen logs a message about a frame, or a slice, of n bytes.
+// It is used for frequent messages; unlike with log, the
+// attribute is only created if the level is enabled.
+func (inst *Inst) logLen(level Level, msg string, n int) {
+	if inst.logEnabled(level) {
+		inst.log(level, msg, Attr{KeyLen, n})
+	}
+}
+
+// debugFuncs adapts the deprecated fields DebugInfo
+// and DebugError to the Logger interface.
+type debugFuncs struct {
+	info func(msg string, a ...any)
+	err  func(msg string, a ...any)
+}
+
+func (d debugFuncs) fn(level Level) func(msg string, a ...any) {
+	switch {
+	case level >= LevelError:
+		return d.err
+	case level >= LevelDebug:
+		return d.info
+	}
+	return nil
+}
+
+func (d debugFuncs) Enabled(level Level) bool {
+	return d.fn(level) != nil
+}
+
+func (d debugFuncs) Log(level Level, msg string, attrs ...Attr) {
+	a := make([]any, 0, 2*len(attrs))
+	for _, attr := range attrs {
+		a = append(a, attr.Key, attr.Value)
+	}
+	d.fn(level)(msg, a...)
+}
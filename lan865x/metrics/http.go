@@ -0,0 +1,57 @@
+//go:build !tinygo
+
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+
+	"github.com/knieriem/t1s/lan865x"
+)
+
+// Handler returns an HTTP handler serving the
+// statistics of inst in the Prometheus text format.
+func Handler(inst *lan865x.Inst) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := inst.Stats()
+		w.Header().Set("Content-Type", ContentType)
+		WritePrometheus(w, &s)
+	})
+}
+
+// Publish publishes the statistics of inst as an
+// expvar variable of the given name. Like other
+// expvar variables, it is served at /debug/vars
+// by the http.DefaultServeMux.
+func Publish(name string, inst *lan865x.Inst) {
+	expvar.Publish(name, expvar.Func(func() any {
+		s := inst.Stats()
+		return expvarStats(&s)
+	}))
+}
+
+// expvarStats returns s as a map, with events and
+// protocol errors mapped by name, resp. code,
+// omitting zero counts.
+func expvarStats(s *lan865x.Stats) map[string]any {
+	m := make(map[string]any, len(metrics)+2)
+	for i := range metrics {
+		m[metrics[i].name] = metrics[i].value(s)
+	}
+	events := make(map[string]uint64)
+	for i, n := range s.Events {
+		if n != 0 {
+			events[lan865x.Event(i).String()] = n
+		}
+	}
+	m["events_total"] = events
+	errs := make(map[string]uint64)
+	for i, n := range s.ProtoErrors {
+		if n != 0 {
+			errs[strconv.Itoa(i)] = n
+		}
+	}
+	m["proto_errors_total"] = errs
+	return m
+}
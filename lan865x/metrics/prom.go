@@ -0,0 +1,119 @@
+// Package metrics exports the counters and status of a
+// [lan865x.Inst] in the Prometheus text exposition format,
+// and, on hosts, via an HTTP handler and package expvar.
+//
+// WritePrometheus does not depend on net/http, so that it may
+// also be used with embedded HTTP servers, like seqs' on TinyGo.
+package metrics
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/knieriem/t1s/lan865x"
+)
+
+// ContentType is the content type of the Prometheus
+// text exposition format.
+const ContentType = "text/plain; version=0.0.4"
+
+// Prefix is the prefix of the metric names.
+const Prefix = "lan865x_"
+
+type metric struct {
+	name, help string
+	counter    bool
+	value      func(s *lan865x.Stats) uint64
+}
+
+var metrics = []metric{
+	{"rx_frames_total", "Frames accepted for delivery to the upper layer.", true, func(s *lan865x.Stats) uint64 { return s.RxFrames }},
+	{"rx_bytes_total", "Bytes accepted for delivery to the upper layer.", true, func(s *lan865x.Stats) uint64 { return s.RxBytes }},
+	{"rx_errors_total", "Frames dropped due to an invalid state or length.", true, func(s *lan865x.Stats) uint64 { return s.RxErrors }},
+	{"rx_filtered_total", "Frames dropped by the address filter.", true, func(s *lan865x.Stats) uint64 { return s.RxFiltered }},
+	{"rx_upper_errors_total", "Frames rejected by the upper layer.", true, func(s *lan865x.Stats) uint64 { return s.RxUpperErrors }},
+	{"rx_overflows_total", "Frames dropped because no receive buffer was available.", true, func(s *lan865x.Stats) uint64 { return s.RxOverflows }},
+	{"tx_frames_total", "Frames transmitted.", true, func(s *lan865x.Stats) uint64 { return s.TxFrames }},
+	{"tx_bytes_total", "Bytes transmitted.", true, func(s *lan865x.Stats) uint64 { return s.TxBytes }},
+	{"tx_errors_total", "Frames that could not be submitted.", true, func(s *lan865x.Stats) uint64 { return s.TxErrors }},
+	{"tx_overflows_total", "Frames rejected because no transmit buffer was available.", true, func(s *lan865x.Stats) uint64 { return s.TxOverflows }},
+	{"reinits_total", "Reinitializations of the MAC-PHY.", true, func(s *lan865x.Stats) uint64 { return s.Reinits }},
+	{"wakeups_total", "Wake-ups of the MAC-PHY after sleep.", true, func(s *lan865x.Stats) uint64 { return s.Wakeups }},
+	{"link_up", "Whether the MAC-PHY is initialized.", false, func(s *lan865x.Stats) uint64 { return b2u(s.LinkUp) }},
+	{"plca_enabled", "Whether PLCA is enabled.", false, func(s *lan865x.Stats) uint64 { return b2u(s.PLCAEnabled) }},
+	{"plca_active", "Whether PLCA beacons are received or sent.", false, func(s *lan865x.Stats) uint64 { return b2u(s.PLCAActive) }},
+	{"plca_node_id", "PLCA node ID.", false, func(s *lan865x.Stats) uint64 { return uint64(s.PLCANodeID) }},
+	{"plca_node_count", "PLCA node count.", false, func(s *lan865x.Stats) uint64 { return uint64(s.PLCANodeCount) }},
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WritePrometheus writes s in the Prometheus text exposition format.
+// Events and protocol errors are exported as counters
+// lan865x_events_total and lan865x_proto_errors_total,
+// labeled by the event name, resp. the error code;
+// to keep the output small, zero counts are omitted.
+func WritePrometheus(w io.Writer, s *lan865x.Stats) error {
+	b := make([]byte, 0, 256)
+	for i := range metrics {
+		m := &metrics[i]
+		b = appendHeader(b, m.name, m.help, m.counter)
+		b = appendSample(b, m.name, "", "", m.value(s))
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		b = b[:0]
+	}
+
+	b = appendHeader(b, "events_total", "Events reported by the MAC-PHY.", true)
+	for i, n := range s.Events {
+		if n == 0 {
+			continue
+		}
+		b = appendSample(b, "events_total", "event", lan865x.Event(i).String(), n)
+	}
+	b = appendHeader(b, "proto_errors_total", "TC6 protocol errors.", true)
+	for i, n := range s.ProtoErrors {
+		if n == 0 {
+			continue
+		}
+		b = appendSample(b, "proto_errors_total", "code", strconv.Itoa(i), n)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func appendHeader(b []byte, name, help string, counter bool) []byte {
+	b = append(b, "# HELP "+Prefix...)
+	b = append(b, name...)
+	b = append(b, ' ')
+	b = append(b, help...)
+	b = append(b, "\n# TYPE "+Prefix...)
+	b = append(b, name...)
+	if counter {
+		b = append(b, " counter\n"...)
+	} else {
+		b = append(b, " gauge\n"...)
+	}
+	return b
+}
+
+func appendSample(b []byte, name, label, labelValue string, v uint64) []byte {
+	b = append(b, Prefix...)
+	b = append(b, name...)
+	if label != "" {
+		b = append(b, '{')
+		b = append(b, label...)
+		b = append(b, "=\""...)
+		b = append(b, labelValue...)
+		b = append(b, "\"}"...)
+	}
+	b = append(b, ' ')
+	b = strconv.AppendUint(b, v, 10)
+	return append(b, '\n')
+}
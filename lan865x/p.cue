@@ -1,3 +1,5 @@
+// The vendored sources carry local changes, kept in oa-tc6.patch,
+// which must be reapplied after a refresh: patch -p1 < oa-tc6.patch
 require: {
 	"lib/oa-tc6/src": "github.com/MicrochipTech/oa-tc6-lib@V3.1.3:libtc6/src"
 	"lib/oa-tc6/inc": "github.com/MicrochipTech/oa-tc6-lib@V3.1.3:libtc6/inc"
@@ -0,0 +1,11 @@
+module github.com/knieriem/t1s/lan865x/periphdev
+
+go 1.21
+
+require (
+	github.com/knieriem/t1s v0.0.0-20240506205313-189e7e6390fe
+	periph.io/x/conn/v3 v3.7.0
+	periph.io/x/host/v3 v3.8.2
+)
+
+replace github.com/knieriem/t1s => ../../
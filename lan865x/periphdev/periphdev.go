@@ -0,0 +1,264 @@
+// Package periphdev provides a LAN865x hardware interface for Linux
+// hosts like the Raspberry Pi, using periph.io to access the SPI device
+// and the GPIO pins connected to the reset and interrupt lines,
+// and, optionally, to the WAKE_IN pin.
+//
+// The package is a module of its own, so that the
+// driver module does not depend on periph.io.
+package periphdev
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi"
+	"periph.io/x/conn/v3/spi/spireg"
+	"periph.io/x/host/v3"
+
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/tc6"
+	"github.com/knieriem/t1s/lan865x/tc6/spirec"
+	"github.com/knieriem/t1s/lan865x/tc6/spitrace"
+	"github.com/knieriem/t1s/nodeconf"
+)
+
+// Conf defines how a LAN865x is connected.
+type Conf struct {
+	SPIDev   string
+	SPIFreq  physic.Frequency
+	SPIMode  spi.Mode
+	ResetPin string
+	IntrPin  string
+
+	// WakePin, if not empty, names the pin connected to WAKE_IN,
+	// which is pulsed to wake up the MAC-PHY; otherwise, if
+	// HardReset is set, Wake falls back to Reset.
+	WakePin string
+
+	// HardReset enables pulsing the reset pin
+	// when the driver requests a reset.
+	HardReset bool
+
+	// RecordFile, if not empty, names a file SPI transfers
+	// are recorded to, see package spirec.
+	RecordFile string
+
+	// TraceSPI enables decoded traces of SPI transfers.
+	TraceSPI bool
+}
+
+// DefaultConf matches the wiring used by the HTTP server example
+// on a Raspberry Pi.
+var DefaultConf = Conf{
+	SPIDev:   "/dev/spidev0.1",
+	SPIFreq:  5 * physic.MegaHertz,
+	ResetPin: "GPIO13",
+	IntrPin:  "GPIO26",
+}
+
+// RegisterFlags defines command line flags in fs
+// that modify the fields of c.
+func (c *Conf) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.SPIDev, "spidev", c.SPIDev, "name of the SPI device")
+	fs.Var(&c.SPIFreq, "spi-freq", "SPI clock frequency")
+	fs.StringVar(&c.ResetPin, "reset-pin", c.ResetPin, "name of LAN865x reset pin")
+	fs.StringVar(&c.IntrPin, "intr-pin", c.IntrPin, "name of LAN865x interrupt pin")
+	fs.StringVar(&c.WakePin, "wake-pin", c.WakePin, "name of the pin connected to LAN865x WAKE_IN")
+	fs.BoolVar(&c.HardReset, "hw-reset", c.HardReset, "reset the LAN865x using the reset pin")
+	fs.StringVar(&c.RecordFile, "record", c.RecordFile, "record SPI transfers to `file`")
+	fs.BoolVar(&c.TraceSPI, "S", c.TraceSPI, "enable decoded SPI traces at log level d-4")
+}
+
+// Apply overrides the fields of c with the
+// settings specified in hw.
+func (c *Conf) Apply(hw *nodeconf.Hardware) {
+	if hw.SPIDev != "" {
+		c.SPIDev = hw.SPIDev
+	}
+	if hw.SPIClockHz != 0 {
+		c.SPIFreq = physic.Frequency(hw.SPIClockHz) * physic.Hertz
+	}
+	if hw.SPIMode != 0 {
+		c.SPIMode = spi.Mode(hw.SPIMode)
+	}
+	if hw.ResetPin != "" {
+		c.ResetPin = hw.ResetPin
+	}
+	if hw.IntrPin != "" {
+		c.IntrPin = hw.IntrPin
+	}
+	if hw.WakePin != "" {
+		c.WakePin = hw.WakePin
+	}
+}
+
+// Dev is an opened LAN865x hardware interface.
+type Dev struct {
+	conf     Conf
+	port     spi.PortCloser
+	conn     spi.Conn
+	resetPin gpio.PinOut
+	intrPin  gpio.PinIn
+	wakePin  gpio.PinOut
+	record   io.WriteCloser
+}
+
+// Open initializes periph.io, and opens the SPI device
+// and the GPIO pins configured in c.
+func Open(c *Conf) (*Dev, error) {
+	_, err := host.Init()
+	if err != nil {
+		return nil, err
+	}
+	d := &Dev{conf: *c}
+	d.resetPin, err = lookupPin(c.ResetPin)
+	if err != nil {
+		return nil, err
+	}
+	err = d.resetPin.Out(gpio.High)
+	if err != nil {
+		return nil, err
+	}
+	d.intrPin, err = lookupPin(c.IntrPin)
+	if err != nil {
+		return nil, err
+	}
+	err = d.intrPin.In(gpio.PullNoChange, gpio.NoEdge)
+	if err != nil {
+		return nil, err
+	}
+	if c.WakePin != "" {
+		d.wakePin, err = lookupPin(c.WakePin)
+		if err != nil {
+			return nil, err
+		}
+		err = d.wakePin.Out(gpio.Low)
+		if err != nil {
+			return nil, err
+		}
+	}
+	port, err := spireg.Open(c.SPIDev)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := port.Connect(c.SPIFreq, c.SPIMode, 8)
+	if err != nil {
+		port.Close()
+		return nil, err
+	}
+	d.port = port
+	d.conn = conn
+	if c.RecordFile != "" {
+		f, err := os.Create(c.RecordFile)
+		if err != nil {
+			port.Close()
+			return nil, err
+		}
+		d.record = f
+	}
+	return d, nil
+}
+
+// HwIntf returns the hardware interface to be used by the driver.
+// Depending on the configuration, it is wrapped by a recorder,
+// and by an SPI tracer logging to traceLog.
+func (d *Dev) HwIntf(traceLog *slog.Logger) lan865x.HwIntf {
+	var hwi lan865x.HwIntf = d
+	if d.record != nil {
+		hwi = spirec.NewRecorder(hwi, d.record)
+	}
+	if d.conf.TraceSPI && traceLog != nil {
+		hwi = &spitrace.Intf{
+			HwIntf: hwi,
+			Log:    traceLog,
+		}
+	}
+	return hwi
+}
+
+// Close closes the SPI port and the recording file, if any.
+func (d *Dev) Close() error {
+	err := d.port.Close()
+	if d.record != nil {
+		if err1 := d.record.Close(); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+func (d *Dev) Reset() error {
+	if !d.conf.HardReset {
+		return nil
+	}
+	err := d.resetPin.Out(gpio.Low)
+	if err != nil {
+		return err
+	}
+	time.Sleep(10 * time.Millisecond)
+	err = d.resetPin.Out(gpio.High)
+	time.Sleep(10 * time.Millisecond)
+	return err
+}
+
+// Wake pulses the WAKE_IN pin, if configured; otherwise a hardware
+// reset is performed, if enabled. If neither is possible,
+// [tc6.ErrNoWake] is returned.
+func (d *Dev) Wake() error {
+	if d.wakePin == nil {
+		if !d.conf.HardReset {
+			return tc6.ErrNoWake
+		}
+		return d.Reset()
+	}
+	err := d.wakePin.Out(gpio.High)
+	if err != nil {
+		return err
+	}
+	time.Sleep(time.Millisecond)
+	return d.wakePin.Out(gpio.Low)
+}
+
+func (d *Dev) IntrActive() bool {
+	return d.intrPin.Read() == gpio.Low
+}
+
+func (d *Dev) SpiTxRx(tx, rx []byte, done func(error)) error {
+	err := d.conn.Tx(tx, rx)
+	done(err)
+	return err
+}
+
+type pinError string
+
+func (e pinError) Error() string {
+	return "pin not found: " + string(e)
+}
+
+func lookupPin(name string) (gpio.PinIO, error) {
+	pin := gpioreg.ByName(name)
+	if pin == nil {
+		return nil, pinError(name)
+	}
+	return pin, nil
+}
+
+// Ticks is a [lan865x.TicksProvider] based on the system's monotonic clock.
+type Ticks struct {
+	t0 time.Time
+}
+
+// NewTicks returns a ticks provider starting at zero.
+func NewTicks() *Ticks {
+	return &Ticks{t0: time.Now()}
+}
+
+func (tp *Ticks) Milliseconds() uint32 {
+	return uint32(time.Since(tp.t0) / time.Millisecond)
+}
@@ -0,0 +1,222 @@
+package lan865x
+
+// #include <tc6.h>
+// #include <tc6-regs.h>
+import "C"
+
+import (
+	"errors"
+	"time"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// PowerState is the power state of the MAC-PHY, as managed by the driver.
+type PowerState uint8
+
+const (
+	PowerOn         PowerState = iota // normal operation
+	PowerSleep                        // asleep; the SPI is not accessed
+	PowerWaking                       // woken up, being reinitialized
+	PowerWakeFailed                   // not reinitialized in time after a wake-up; still retried
+)
+
+var powerStateNames = [...]string{
+	PowerOn:         "on",
+	PowerSleep:      "sleep",
+	PowerWaking:     "waking",
+	PowerWakeFailed: "wake failed",
+}
+
+func (s PowerState) String() string {
+	if int(s) < len(powerStateNames) {
+		return powerStateNames[s]
+	}
+	return "unknown"
+}
+
+// WakeSource tells what caused a wake-up.
+type WakeSource uint8
+
+const (
+	// WakeHost means the host called [Inst.Wake].
+	WakeHost WakeSource = iota
+
+	// WakeExternal means the MAC-PHY woke up by itself, because
+	// of a wake-up signaled on the bus, or at its WAKE_IN pin
+	// by other circuitry, and asserted its interrupt line.
+	WakeExternal
+)
+
+func (s WakeSource) String() string {
+	if s == WakeHost {
+		return "host"
+	}
+	return "external"
+}
+
+// Waker may be implemented by a [HwIntf] able to wake up
+// the MAC-PHY, see [Inst.Wake].
+type Waker = tc6.Waker
+
+// ErrNoWake is returned by [Inst.Wake] if the MAC-PHY
+// cannot be woken up by the host.
+var ErrNoWake = tc6.ErrNoWake
+
+// SleepConf defines which events wake up a sleeping MAC-PHY.
+type SleepConf struct {
+	// WakeOnBus enables wake-up by 10BASE-T1S
+	// wake-up signaling on the bus.
+	WakeOnBus bool
+
+	// WakeOnPin enables wake-up by the WAKE_IN pin.
+	WakeOnPin bool
+
+	// ForwardWake makes the MAC-PHY, when woken up at its WAKE_IN
+	// pin, signal the wake-up on the bus, so that sleeping nodes
+	// of the segment with WakeOnBus set wake up too.
+	ForwardWake bool
+}
+
+var (
+	ErrAsleep    = errors.New("lan865x: MAC-PHY asleep")
+	ErrNotActive = errors.New("lan865x: MAC-PHY not in normal operation")
+	ErrTxPending = errors.New("lan865x: transmission not completed")
+)
+
+// sleepTxTimeout limits the time Sleep waits
+// for a transmission in progress to complete.
+const sleepTxTimeout = 100 * time.Millisecond
+
+// wakeTimeout is the time after a wake-up within which
+// the MAC-PHY is expected to be initialized again.
+const wakeTimeout = time.Second
+
+// Bits of the deep sleep control registers.
+const (
+	sleepEnable  = 1 << 15 // DEEP_SLEEP_CTRL_0: enter sleep
+	sleepWakeIn  = 1 << 14 // DEEP_SLEEP_CTRL_0: WAKE_IN wake-up enable
+	sleepWakeMDI = 1 << 13 // DEEP_SLEEP_CTRL_0: bus wake-up enable
+	sleepWakeFwd = 1 << 0  // DEEP_SLEEP_CTRL_1: wake-up forwarding enable
+)
+
+// PowerState returns the power state of the MAC-PHY.
+// It may be called from any goroutine.
+func (inst *Inst) PowerState() PowerState {
+	return PowerState(inst.power.Load())
+}
+
+func (inst *Inst) setPowerState(s PowerState) {
+	inst.power.Store(uint32(s))
+	inst.log(LevelInfo, "power", Attr{"state", s.String()})
+}
+
+// Sleep puts the MAC-PHY to sleep, with wake-up sources enabled as
+// defined by c. Frames already handed to the oa-tc6 library are
+// transmitted first; frames still queued are kept until the
+// MAC-PHY has been woken up; if a transmission does not
+// complete in time, ErrTxPending is returned. While the MAC-PHY
+// is asleep, Service does not access the SPI, and does not poll
+// the upper layer; register accesses fail with ErrAsleep.
+//
+// Like [Inst.ReadReg], Sleep must be called
+// from the goroutine running [Inst.Service].
+func (inst *Inst) Sleep(c *SleepConf) error {
+	if inst.PowerState() != PowerOn {
+		return ErrNotActive
+	}
+	deadline := inst.deadline(sleepTxTimeout)
+	for inst.txq.busy != -1 {
+		if inst.expired(deadline) {
+			return ErrTxPending
+		}
+		C.TC6_Service(inst.tc6, cBool(true))
+	}
+	var fwd uint32
+	if c.ForwardWake {
+		fwd = sleepWakeFwd
+	}
+	_, err := inst.ModifyReg(tc6.RegDeepSleepCtrl1, fwd, sleepWakeFwd)
+	if err != nil {
+		return err
+	}
+	v := uint32(sleepEnable)
+	if c.WakeOnPin {
+		v |= sleepWakeIn
+	}
+	if c.WakeOnBus {
+		v |= sleepWakeMDI
+	}
+	_, err = inst.ModifyReg(tc6.RegDeepSleepCtrl0, v, sleepEnable|sleepWakeIn|sleepWakeMDI)
+	if err != nil {
+		return err
+	}
+	inst.needService = false
+	st := &inst.counters.status
+	st.Store(st.Load() &^ stLinkUp)
+	inst.setPowerState(PowerSleep)
+	return nil
+}
+
+// Wake wakes up a sleeping MAC-PHY using the Wake method of Dev.
+// If Dev does not implement [Waker], ErrNoWake is returned; since
+// Reset of a HwIntf may do nothing, like if no reset line is
+// connected, it is not used instead. The driver then reinitializes
+// the MAC-PHY, restoring its configuration, and calls OnWake,
+// once initialization is done. If that does not happen within
+// a second, the power state changes to PowerWakeFailed, and
+// EventInitFailed is reported; reinitialization is still retried,
+// and Wake may be called again. In other states than PowerSleep
+// and PowerWakeFailed, Wake does nothing.
+//
+// Wake must be called from the goroutine running [Inst.Service].
+func (inst *Inst) Wake() error {
+	if s := inst.PowerState(); s != PowerSleep && s != PowerWakeFailed {
+		return nil
+	}
+	w, ok := inst.Dev.(Waker)
+	if !ok {
+		return ErrNoWake
+	}
+	if err := w.Wake(); err != nil {
+		return err
+	}
+	inst.wake(WakeHost)
+	return nil
+}
+
+// wake makes Service reinitialize the MAC-PHY.
+func (inst *Inst) wake(src WakeSource) {
+	inst.wakeSrc = src
+	inst.wakeDeadline = inst.deadline(wakeTimeout)
+	inst.counters.wakeups.Add(1)
+	C.TC6Regs_Reinit(inst.tc6)
+	inst.needService = true
+	inst.setPowerState(PowerWaking)
+}
+
+// servicePower is called by Service. While the MAC-PHY is asleep,
+// it checks the interrupt line, which gets asserted once the MAC-PHY
+// has woken up, and reports whether Service shall return early.
+// After initialization following a wake-up, it calls OnWake.
+func (inst *Inst) servicePower() (skip bool) {
+	switch s := inst.PowerState(); s {
+	case PowerSleep:
+		if !inst.Dev.IntrActive() {
+			return true
+		}
+		inst.wake(WakeExternal)
+	case PowerWaking, PowerWakeFailed:
+		if C.TC6Regs_GetInitDone(inst.tc6) != 0 {
+			inst.setPowerState(PowerOn)
+			if inst.OnWake != nil {
+				inst.OnWake(inst.wakeSrc)
+			}
+		} else if s == PowerWaking && inst.expired(inst.wakeDeadline) {
+			inst.log(LevelError, "wake: MAC-PHY not initialized in time")
+			inst.setPowerState(PowerWakeFailed)
+			inst.onEvent(EventInitFailed)
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package lan865x
+
+// #include <stdint.h>
+// #include <tc6.h>
+//
+// extern	int	t1s_accessMap(TC6_t *pInst, const MemoryMap_t *pMap, uint16_t n);
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// RegOp is a register write of an init profile. If Mask is not
+// zero, it is a read-modify-write, changing only the bits
+// of the register that are set in Mask.
+type RegOp struct {
+	Addr  uint32 // contains the memory map selector in bits 16..19
+	Value uint32
+	Mask  uint32
+}
+
+// InitProfile contains register settings, like errata fixes or tuned
+// PHY parameters, that are applied during initialization of the MAC-PHY.
+type InitProfile struct {
+	// Revision is the silicon revision the profile applies to;
+	// if zero, it applies to all revisions.
+	Revision uint8
+
+	Regs []RegOp
+}
+
+// InitError is returned by [Inst.Init] if a register
+// access of an init profile failed.
+type InitError struct {
+	Addr uint32
+}
+
+func (e *InitError) Error() string {
+	return "lan865x: init profile: access to " + tc6.FormatAddr(e.Addr) + " failed"
+}
+
+type mapAccess struct {
+	pending int
+	failed  bool
+	addr    uint32 // address of the first failed access
+}
+
+// applyProfiles applies the entries of the init profiles
+// matching chipRev, in order, using protected accesses.
+func (inst *Inst) applyProfiles(chipRev uint8) error {
+	var m []C.MemoryMap_t
+	for i := range inst.InitProfiles {
+		p := &inst.InitProfiles[i]
+		if p.Revision != 0 && p.Revision != chipRev {
+			continue
+		}
+		for _, r := range p.Regs {
+			e := C.MemoryMap_t{
+				address: C.uint32_t(r.Addr),
+				value:   C.uint32_t(r.Value),
+				mask:    C.uint32_t(r.Mask),
+				op:      C.MemOp_Write,
+				secure:  1,
+			}
+			if r.Mask != 0 {
+				// The library does not mask the value itself.
+				e.value &= C.uint32_t(r.Mask)
+				e.op = C.MemOp_ReadModifyWrite
+			}
+			m = append(m, e)
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	a := &inst.mapAccess
+	a.pending = len(m)
+	a.failed = false
+	for i := 0; i < len(m); {
+		n := int(C.t1s_accessMap(inst.tc6, &m[i], C.uint16_t(min(len(m)-i, 0xFFFF))))
+		i += n
+		if i < len(m) {
+			C.TC6_Service(inst.tc6, cBool(true))
+		}
+	}
+	for a.pending > 0 {
+		C.TC6_Service(inst.tc6, cBool(true))
+	}
+	if a.failed {
+		return &InitError{Addr: a.addr}
+	}
+	return nil
+}
+
+//export t1s_onMapAccess
+func t1s_onMapAccess(gTag unsafe.Pointer, success C.int, addr uint32) {
+	inst := instFromHandle(gTag)
+	a := &inst.mapAccess
+	a.pending--
+	if success == 0 && !a.failed {
+		a.failed = true
+		a.addr = addr
+	}
+}
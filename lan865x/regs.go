@@ -0,0 +1,104 @@
+package lan865x
+
+// #include <stdint.h>
+// #include <tc6.h>
+//
+// extern	int	t1s_readReg(TC6_t *pInst, uint32_t addr, uint32_t seq);
+// extern	int	t1s_writeReg(TC6_t *pInst, uint32_t addr, uint32_t value, uint32_t seq);
+// extern	int	t1s_modifyReg(TC6_t *pInst, uint32_t addr, uint32_t value, uint32_t mask, uint32_t seq);
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+// RegTimeout is the time after which a register access
+// that has not been completed fails with ErrRegsFailure.
+const RegTimeout = 100 * time.Millisecond
+
+type regAccess struct {
+	seq     uint32 // incremented for each access
+	pending bool
+	ok      bool
+	value   uint32
+}
+
+// ReadReg reads the register at addr, which contains the memory map
+// selector in bits 16..19, as defined in package tc6. ReadReg, as well as [Inst.WriteReg] and
+// [Inst.ModifyReg], use protected control transactions. They must not
+// be called from within [t1s.UpperProto] methods.
+func (inst *Inst) ReadReg(addr uint32) (uint32, error) {
+	return inst.accessReg(func(seq C.uint32_t) C.int {
+		return C.t1s_readReg(inst.tc6, C.uint32_t(addr), seq)
+	})
+}
+
+// WriteReg writes value to the register at addr.
+func (inst *Inst) WriteReg(addr, value uint32) error {
+	_, err := inst.accessReg(func(seq C.uint32_t) C.int {
+		return C.t1s_writeReg(inst.tc6, C.uint32_t(addr), C.uint32_t(value), seq)
+	})
+	return err
+}
+
+// ModifyReg changes those bits of the register at addr that are set in mask
+// to the corresponding bits of value. The resulting register value is returned.
+func (inst *Inst) ModifyReg(addr, value, mask uint32) (uint32, error) {
+	return inst.accessReg(func(seq C.uint32_t) C.int {
+		return C.t1s_modifyReg(inst.tc6, C.uint32_t(addr), C.uint32_t(value), C.uint32_t(mask), seq)
+	})
+}
+
+func (inst *Inst) accessReg(enqueue func(seq C.uint32_t) C.int) (uint32, error) {
+	if inst.tc6 == nil {
+		return 0, ErrRegsFailure
+	}
+	if inst.PowerState() == PowerSleep {
+		return 0, ErrAsleep
+	}
+	r := &inst.reg
+	r.seq++
+	r.pending = true
+	deadline := inst.deadline(RegTimeout)
+	for enqueue(C.uint32_t(r.seq)) == 0 {
+		if inst.expired(deadline) {
+			r.pending = false
+			return 0, ErrRegsFailure
+		}
+		C.TC6_Service(inst.tc6, cBool(true))
+	}
+	for r.pending {
+		if inst.expired(deadline) {
+			r.pending = false
+			return 0, ErrRegsFailure
+		}
+		C.TC6_Service(inst.tc6, cBool(true))
+	}
+	if !r.ok {
+		return 0, ErrRegsFailure
+	}
+	return r.value, nil
+}
+
+//export t1s_onRegAccess
+func t1s_onRegAccess(gTag unsafe.Pointer, seq uint32, success C.int, value uint32) {
+	inst := instFromHandle(gTag)
+	r := &inst.reg
+	if !r.pending || seq != r.seq {
+		return
+	}
+	r.pending = false
+	r.ok = success != 0
+	r.value = value
+}
+
+// deadline returns the time, according to the
+// ticks provider, at which a timeout d expires.
+func (inst *Inst) deadline(d time.Duration) uint32 {
+	return inst.ticks().Milliseconds() + uint32(d/time.Millisecond)
+}
+
+func (inst *Inst) expired(deadline uint32) bool {
+	return int32(inst.ticks().Milliseconds()-deadline) >= 0
+}
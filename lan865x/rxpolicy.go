@@ -0,0 +1,78 @@
+package lan865x
+
+import (
+	"errors"
+	"hash/crc32"
+
+	"github.com/knieriem/t1s"
+)
+
+const (
+	// DefaultRxMinLen is the minimum length of a received frame,
+	// including the FCS, used if RxPolicy.MinLen is zero:
+	// the size of an Ethernet header plus the FCS.
+	DefaultRxMinLen = t1s.HeaderLen + t1s.FCSLen
+
+	// DefaultRxMaxLen is the maximum length of a received frame,
+	// including the FCS, used if RxPolicy.MaxLen is zero:
+	// the size of a maximum VLAN tagged frame.
+	DefaultRxMaxLen = t1s.MaxFrameLen + t1s.VLANTagLen
+)
+
+var (
+	ErrRxInvalid  = errors.New("lan865x: rx: invalid frame reassembly")
+	ErrRxLength   = errors.New("lan865x: rx: length mismatch")
+	ErrRxTooShort = errors.New("lan865x: rx: frame too short")
+	ErrRxTooLong  = errors.New("lan865x: rx: frame too long")
+	ErrRxFCS      = errors.New("lan865x: rx: FCS mismatch")
+)
+
+// RxPolicy defines which received frames
+// are delivered to the upper layer, and how.
+// The LAN865x delivers frames including the FCS.
+type RxPolicy struct {
+	// MinLen and MaxLen define the range of accepted frame lengths,
+	// including the FCS. If zero, DefaultRxMinLen,
+	// resp. DefaultRxMaxLen, are used.
+	MinLen int
+	MaxLen int
+
+	// CheckFCS enables verification of the FCS by the driver.
+	CheckFCS bool
+
+	// StripFCS removes the FCS from frames before delivery.
+	StripFCS bool
+
+	// DeliverErrors requests frames not passing the checks
+	// to be delivered instead of being dropped, provided that they
+	// contain data. Only upper layers implementing [RxBufReceiver]
+	// receive such frames; [RxBuf.Err] reports the error. The address
+	// filter is not applied to these frames.
+	DeliverErrors bool
+}
+
+func (p *RxPolicy) check(frame []byte) error {
+	min, max := p.MinLen, p.MaxLen
+	if min == 0 {
+		min = DefaultRxMinLen
+	}
+	if max == 0 {
+		max = DefaultRxMaxLen
+	}
+	n := len(frame)
+	switch {
+	case n < min || n < t1s.FCSLen:
+		return ErrRxTooShort
+	case n > max:
+		return ErrRxTooLong
+	case p.CheckFCS && !fcsValid(frame):
+		return ErrRxFCS
+	}
+	return nil
+}
+
+func fcsValid(frame []byte) bool {
+	n := len(frame) - t1s.FCSLen
+	fcs := uint32(frame[n]) | uint32(frame[n+1])<<8 | uint32(frame[n+2])<<16 | uint32(frame[n+3])<<24
+	return crc32.ChecksumIEEE(frame[:n]) == fcs
+}
@@ -0,0 +1,183 @@
+package lan865x
+
+import (
+	"sync"
+)
+
+// DefaultRxQueueLen is the number of receive buffers, of MTU bytes
+// each, used if Inst.RxQueueLen is zero. Since a single service call
+// may receive several frames, the queue should be able to hold the
+// frames arriving between two calls of Service.
+const DefaultRxQueueLen = 8
+
+// RxOverflowPolicy defines how the driver handles a frame
+// being received while all receive buffers are in use.
+type RxOverflowPolicy uint8
+
+const (
+	// DropNewest drops the frame being received.
+	DropNewest RxOverflowPolicy = iota
+
+	// DropOldest drops the oldest frame not yet delivered to the upper layer,
+	// and reuses its buffer. If no such frame exists, because all buffers
+	// are owned by the upper layer, the frame being received is dropped.
+	DropOldest
+)
+
+// RxBuf is a buffer containing a received frame. Unless
+// it has been passed to [RxBufReceiver.ReceiveBuf], the buffer is
+// owned by the driver.
+type RxBuf struct {
+	buf []byte
+	err error
+	q   *rxQueue
+
+	// Guarded by q.mu:
+	gen      uint32 // generation of the queue the buffer belongs to
+	released bool
+}
+
+// Bytes returns the frame, including the FCS,
+// unless RxPolicy.StripFCS is set.
+func (b *RxBuf) Bytes() []byte {
+	return b.buf
+}
+
+// Err returns the error detected while receiving the frame,
+// if it has been delivered because of RxPolicy.DeliverErrors.
+func (b *RxBuf) Err() error {
+	return b.err
+}
+
+// Release returns the buffer to the driver. It may be called
+// from any goroutine. The buffer must not be used afterwards.
+// Releasing a buffer more than once has no effect, as well as
+// releasing it after the driver has been initialized again.
+func (b *RxBuf) Release() {
+	b.q.put(b)
+}
+
+// RxBufReceiver may be implemented by an [t1s.UpperProto] that wants to
+// keep received frames beyond the call that delivers them.
+// If implemented, the driver calls ReceiveBuf instead of SendEthUp,
+// passing the ownership of the buffer, which must be released
+// after use using [RxBuf.Release].
+type RxBufReceiver interface {
+	ReceiveBuf(b *RxBuf) error
+}
+
+// rxQueue manages a fixed number of receive buffers. The buffer currently
+// being filled, and the ring of frames waiting for delivery, are accessed
+// from the goroutine running Service only; the free list is guarded by mu,
+// since buffers may be released by other goroutines.
+type rxQueue struct {
+	bufs []RxBuf
+	cur  *RxBuf
+
+	ring []*RxBuf
+	head int
+	n    int
+
+	mu   sync.Mutex
+	free []*RxBuf
+	gen  uint32 // incremented by init, see RxBuf.gen
+}
+
+func (q *rxQueue) init(n int) {
+	if n <= 0 {
+		n = DefaultRxQueueLen
+	}
+	mem := make([]byte, n*MTU)
+	q.bufs = make([]RxBuf, n)
+	q.cur = nil
+	q.ring = make([]*RxBuf, n)
+	q.head, q.n = 0, 0
+
+	// Buffers of a previous generation, still owned
+	// by the upper layer, are not accepted by put.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.gen++
+	q.free = make([]*RxBuf, 0, n)
+	for i := range q.bufs {
+		b := &q.bufs[i]
+		b.buf = mem[i*MTU : i*MTU : (i+1)*MTU]
+		b.q = q
+		b.gen = q.gen
+		b.released = true
+		q.free = append(q.free, b)
+	}
+}
+
+// get returns a free buffer. If none is available, and the policy
+// is DropOldest, the oldest queued frame is dropped. The second
+// return value reports whether a frame has been dropped.
+func (q *rxQueue) get(policy RxOverflowPolicy) (b *RxBuf, dropped bool) {
+	q.mu.Lock()
+	if n := len(q.free); n != 0 {
+		b = q.free[n-1]
+		q.free = q.free[:n-1]
+		b.released = false
+	}
+	q.mu.Unlock()
+	if b == nil && policy == DropOldest && q.n != 0 {
+		b = q.dequeue()
+		dropped = true
+	}
+	if b != nil {
+		b.buf = b.buf[:0]
+	}
+	return b, dropped
+}
+
+// put adds b to the free list, unless it is already there,
+// or belongs to a previous generation of the queue.
+func (q *rxQueue) put(b *RxBuf) {
+	q.mu.Lock()
+	if !b.released && b.gen == q.gen {
+		b.released = true
+		q.free = append(q.free, b)
+	}
+	q.mu.Unlock()
+}
+
+// enqueue appends b to the ring; since the ring is
+// as large as the number of buffers, it never overflows.
+func (q *rxQueue) enqueue(b *RxBuf) {
+	q.ring[(q.head+q.n)%len(q.ring)] = b
+	q.n++
+}
+
+func (q *rxQueue) dequeue() *RxBuf {
+	if q.n == 0 {
+		return nil
+	}
+	b := q.ring[q.head]
+	q.ring[q.head] = nil
+	q.head = (q.head + 1) % len(q.ring)
+	q.n--
+	return b
+}
+
+// deliverRx passes queued frames to the upper layer.
+func (inst *Inst) deliverRx() {
+	q := &inst.rxq
+	recv, owns := inst.UpperProto.(RxBufReceiver)
+	for {
+		b := q.dequeue()
+		if b == nil {
+			return
+		}
+		var err error
+		if owns {
+			err = recv.ReceiveBuf(b)
+		} else {
+			err = inst.UpperProto.SendEthUp(b.buf)
+			q.put(b)
+		}
+		if err != nil {
+			inst.counters.rxUpperErrors.Add(1)
+			inst.log(LevelError, "deliverRx: upper layer failed", Attr{KeyErr, err})
+		}
+	}
+}
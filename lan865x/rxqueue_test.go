@@ -0,0 +1,31 @@
+package lan865x
+
+import "testing"
+
+func TestRxBufRelease(t *testing.T) {
+	var q rxQueue
+	q.init(2)
+	b, _ := q.get(DropNewest)
+	held, _ := q.get(DropNewest)
+	if b == nil || held == nil {
+		t.Fatal("no buffer")
+	}
+	b.Release()
+	b.Release()
+	if n := len(q.free); n != 1 {
+		t.Fatalf("%d free buffers after a double release, want 1", n)
+	}
+
+	// A buffer held across a reinitialization
+	// must not end up in the new queue.
+	q.init(2)
+	held.Release()
+	if n := len(q.free); n != 2 {
+		t.Fatalf("%d free buffers, want 2", n)
+	}
+	for _, b := range q.free {
+		if b == held {
+			t.Fatal("buffer of a previous generation released into the queue")
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Package slogger adapts a [slog.Handler] to the
+// Logger interface of the LAN865x driver.
+package slogger
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/knieriem/t1s/lan865x"
+)
+
+// Logger passes the log messages of the driver to a slog.Handler.
+type Logger struct {
+	h slog.Handler
+}
+
+// New returns a Logger for h.
+func New(h slog.Handler) *Logger {
+	return &Logger{h: h}
+}
+
+func (l *Logger) Enabled(level lan865x.Level) bool {
+	return l.h.Enabled(context.Background(), slog.Level(level))
+}
+
+func (l *Logger) Log(level lan865x.Level, msg string, attrs ...lan865x.Attr) {
+	r := slog.NewRecord(time.Now(), slog.Level(level), msg, 0)
+	for _, a := range attrs {
+		r.AddAttrs(slog.Any(a.Key, a.Value))
+	}
+	l.h.Handle(context.Background(), r)
+}
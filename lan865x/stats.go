@@ -0,0 +1,146 @@
+package lan865x
+
+// #include <tc6.h>
+// #include <tc6-regs.h>
+import "C"
+
+import (
+	"sync/atomic"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+const (
+	NumEvents      = len(eventNames)
+	NumProtoErrors = len(protoErrorTexts)
+)
+
+// Stats contains the counters maintained by the driver, and
+// the status determined by the last call of [Inst.UpdateStatus].
+type Stats struct {
+	RxFrames      uint64 // frames accepted for delivery to the upper layer
+	RxBytes       uint64
+	RxErrors      uint64 // frames dropped due to an invalid state or length
+	RxFiltered    uint64 // frames dropped by the address filter
+	RxUpperErrors uint64 // frames rejected by the upper layer
+	RxOverflows   uint64 // frames dropped because no receive buffer was available
+	TxFrames      uint64
+	TxBytes       uint64
+	TxErrors      uint64
+	TxOverflows   uint64 // frames rejected by Submit because no transmit buffer was available
+	Reinits       uint64
+	Wakeups       uint64 // wake-ups after Sleep
+
+	Events      [NumEvents]uint64
+	ProtoErrors [NumProtoErrors]uint64
+
+	// LinkUp reports whether the MAC-PHY is initialized,
+	// and no reinitialization is pending.
+	LinkUp bool
+
+	PLCAEnabled   bool
+	PLCAActive    bool // beacons are being received or sent
+	PLCANodeID    uint8
+	PLCANodeCount uint8
+
+	// StatusTime is the time of the last status update,
+	// in milliseconds, as returned by the TicksProvider.
+	StatusTime uint32
+}
+
+// counters may be read by other goroutines
+// while the driver updates them.
+type counters struct {
+	rxFrames      atomic.Uint64
+	rxBytes       atomic.Uint64
+	rxErrors      atomic.Uint64
+	rxFiltered    atomic.Uint64
+	rxUpperErrors atomic.Uint64
+	rxOverflows   atomic.Uint64
+	txFrames      atomic.Uint64
+	txBytes       atomic.Uint64
+	txErrors      atomic.Uint64
+	txOverflows   atomic.Uint64
+	reinits       atomic.Uint64
+	wakeups       atomic.Uint64
+	events        [NumEvents]atomic.Uint64
+	protoErrors   [NumProtoErrors]atomic.Uint64
+
+	status     atomic.Uint32
+	statusTime atomic.Uint32
+}
+
+const (
+	stLinkUp = 1 << iota
+	stPLCAEnabled
+	stPLCAActive
+	stPLCANodeIDShift    = 8
+	stPLCANodeCountShift = 16
+)
+
+// Stats returns a snapshot of the driver's counters and status.
+// It may be called from any goroutine.
+func (inst *Inst) Stats() Stats {
+	c := &inst.counters
+	s := Stats{
+		RxFrames:      c.rxFrames.Load(),
+		RxBytes:       c.rxBytes.Load(),
+		RxErrors:      c.rxErrors.Load(),
+		RxFiltered:    c.rxFiltered.Load(),
+		RxUpperErrors: c.rxUpperErrors.Load(),
+		RxOverflows:   c.rxOverflows.Load(),
+		TxFrames:      c.txFrames.Load(),
+		TxBytes:       c.txBytes.Load(),
+		TxErrors:      c.txErrors.Load(),
+		TxOverflows:   c.txOverflows.Load(),
+		Reinits:       c.reinits.Load(),
+		Wakeups:       c.wakeups.Load(),
+		StatusTime:    c.statusTime.Load(),
+	}
+	for i := range c.events {
+		s.Events[i] = c.events[i].Load()
+	}
+	for i := range c.protoErrors {
+		s.ProtoErrors[i] = c.protoErrors[i].Load()
+	}
+	st := c.status.Load()
+	s.LinkUp = st&stLinkUp != 0
+	s.PLCAEnabled = st&stPLCAEnabled != 0
+	s.PLCAActive = st&stPLCAActive != 0
+	s.PLCANodeID = uint8(st >> stPLCANodeIDShift)
+	s.PLCANodeCount = uint8(st >> stPLCANodeCountShift)
+	return s
+}
+
+// UpdateStatus reads the PLCA registers of the MAC-PHY, and
+// updates the status returned by [Inst.Stats]. Like [Inst.ReadReg],
+// it must be called from the goroutine running [Inst.Service].
+func (inst *Inst) UpdateStatus() error {
+	var st uint32
+	if C.TC6Regs_GetInitDone(inst.tc6) != 0 {
+		st |= stLinkUp
+	}
+	ctrl0, err := inst.ReadReg(tc6.RegPLCACtrl0)
+	if err != nil {
+		return err
+	}
+	ctrl1, err := inst.ReadReg(tc6.RegPLCACtrl1)
+	if err != nil {
+		return err
+	}
+	sts, err := inst.ReadReg(tc6.RegPLCAStatus)
+	if err != nil {
+		return err
+	}
+	if ctrl0&(1<<15) != 0 {
+		st |= stPLCAEnabled
+	}
+	if sts&(1<<15) != 0 {
+		st |= stPLCAActive
+	}
+	st |= (ctrl1 & 0xFF) << stPLCANodeIDShift
+	st |= (ctrl1 >> 8 & 0xFF) << stPLCANodeCountShift
+	inst.counters.status.Store(st)
+	inst.counters.statusTime.Store(inst.ticks().Milliseconds())
+	return nil
+}
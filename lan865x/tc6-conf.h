@@ -83,6 +83,18 @@ extern "C" {
 #define TC6_CHUNK_SIZE      (64u)
 #endif
 
+/**
+ * \brief Defines the value of the Chunk Payload Size field (CPS) of the CONFIG0 register
+ * \note Derived from TC6_CHUNK_SIZE, do not modify.
+ */
+#if (TC6_CHUNK_SIZE == 64u)
+#define TC6_CONFIG0_CPS     (6u)
+#elif (TC6_CHUNK_SIZE == 32u)
+#define TC6_CONFIG0_CPS     (5u)
+#else
+#error "TC6_CHUNK_SIZE must be either 32 or 64"
+#endif
+
 /**
  * \brief Defines the entire payload length of a single TC6 chunk including header / footer.
  * \note Do not modify.
@@ -0,0 +1,191 @@
+package tc6
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DataHeader is the header of a data chunk sent to the MAC-PHY.
+type DataHeader uint32
+
+// Bit positions of data header fields.
+const (
+	hdrDNC  = 31
+	hdrSEQ  = 30
+	hdrNORX = 29
+	hdrDV   = 21
+	hdrSV   = 20
+	hdrSWO  = 16
+	hdrEV   = 14
+	hdrEBO  = 8
+	hdrTSC  = 6
+)
+
+func (h DataHeader) DNC() bool  { return bit(uint32(h), hdrDNC) }
+func (h DataHeader) Seq() bool  { return bit(uint32(h), hdrSEQ) }
+func (h DataHeader) NoRX() bool { return bit(uint32(h), hdrNORX) }
+func (h DataHeader) DV() bool   { return bit(uint32(h), hdrDV) }
+func (h DataHeader) SV() bool   { return bit(uint32(h), hdrSV) }
+func (h DataHeader) EV() bool   { return bit(uint32(h), hdrEV) }
+
+// SWO returns the start of frame word offset.
+func (h DataHeader) SWO() int { return int(field(uint32(h), hdrSWO, 4)) }
+
+// EBO returns the end of frame byte offset.
+func (h DataHeader) EBO() int { return int(field(uint32(h), hdrEBO, 6)) }
+
+// TSC returns the transmit frame timestamp capture selector.
+func (h DataHeader) TSC() int { return int(field(uint32(h), hdrTSC, 2)) }
+
+func (h DataHeader) ParityOK() bool { return ParityOK(uint32(h)) }
+
+// MakeDataHeader returns a data header with the parity bit set.
+func MakeDataHeader(seq, noRX, dv, sv bool, swo int, ev bool, ebo int) DataHeader {
+	w := uint32(1) << hdrDNC
+	w |= flag(seq, hdrSEQ) | flag(noRX, hdrNORX) | flag(dv, hdrDV)
+	w |= flag(sv, hdrSV) | uint32(swo&0xF)<<hdrSWO
+	w |= flag(ev, hdrEV) | uint32(ebo&0x3F)<<hdrEBO
+	return DataHeader(WithParity(w))
+}
+
+func (h DataHeader) String() string {
+	var b strings.Builder
+	b.WriteString("hdr")
+	if !h.DNC() {
+		b.WriteString(" !DNC")
+	}
+	flags(&b, h.Seq(), "SEQ")
+	flags(&b, h.NoRX(), "NORX")
+	flags(&b, h.DV(), "DV")
+	if h.SV() {
+		b.WriteString(" SV@")
+		b.WriteString(strconv.Itoa(h.SWO() * 4))
+	}
+	if h.EV() {
+		b.WriteString(" EV@")
+		b.WriteString(strconv.Itoa(h.EBO()))
+	}
+	if tsc := h.TSC(); tsc != 0 {
+		b.WriteString(" TSC=")
+		b.WriteString(strconv.Itoa(tsc))
+	}
+	flags(&b, !h.ParityOK(), "BADPARITY")
+	return b.String()
+}
+
+// Footer is the footer of a data chunk received from the MAC-PHY.
+type Footer uint32
+
+// Bit positions of footer fields.
+const (
+	ftrEXST = 31
+	ftrHDRB = 30
+	ftrSYNC = 29
+	ftrRCA  = 24
+	ftrDV   = 21
+	ftrSV   = 20
+	ftrSWO  = 16
+	ftrFD   = 15
+	ftrEV   = 14
+	ftrEBO  = 8
+	ftrRTSA = 7
+	ftrRTSP = 6
+	ftrTXC  = 1
+)
+
+// Footer flags.
+const (
+	FooterEXST Footer = 1 << ftrEXST
+	FooterHDRB Footer = 1 << ftrHDRB
+	FooterSync Footer = 1 << ftrSYNC
+	FooterDV   Footer = 1 << ftrDV
+	FooterSV   Footer = 1 << ftrSV
+	FooterFD   Footer = 1 << ftrFD
+	FooterEV   Footer = 1 << ftrEV
+)
+
+func (f Footer) EXST() bool { return bit(uint32(f), ftrEXST) }
+func (f Footer) HDRB() bool { return bit(uint32(f), ftrHDRB) }
+func (f Footer) Sync() bool { return bit(uint32(f), ftrSYNC) }
+func (f Footer) DV() bool   { return bit(uint32(f), ftrDV) }
+func (f Footer) SV() bool   { return bit(uint32(f), ftrSV) }
+func (f Footer) FD() bool   { return bit(uint32(f), ftrFD) }
+func (f Footer) EV() bool   { return bit(uint32(f), ftrEV) }
+func (f Footer) RTSA() bool { return bit(uint32(f), ftrRTSA) }
+func (f Footer) RTSP() bool { return bit(uint32(f), ftrRTSP) }
+
+// RCA returns the number of receive chunks available.
+func (f Footer) RCA() int { return int(field(uint32(f), ftrRCA, 5)) }
+
+// SWO returns the start of frame word offset.
+func (f Footer) SWO() int { return int(field(uint32(f), ftrSWO, 4)) }
+
+// EBO returns the end of frame byte offset.
+func (f Footer) EBO() int { return int(field(uint32(f), ftrEBO, 6)) }
+
+// TXC returns the number of transmit credits.
+func (f Footer) TXC() int { return int(field(uint32(f), ftrTXC, 5)) }
+
+func (f Footer) ParityOK() bool { return ParityOK(uint32(f)) }
+
+// FooterFields contains the values of a [Footer]'s fields.
+type FooterFields struct {
+	EXST, HDRB, Sync bool
+	RCA              int
+	DV, SV           bool
+	SWO              int
+	FD, EV           bool
+	EBO              int
+	RTSA, RTSP       bool
+	TXC              int
+}
+
+// MakeFooter returns a footer with the parity bit set.
+func MakeFooter(ff *FooterFields) Footer {
+	w := flag(ff.EXST, ftrEXST) | flag(ff.HDRB, ftrHDRB) | flag(ff.Sync, ftrSYNC)
+	w |= uint32(ff.RCA&0x1F) << ftrRCA
+	w |= flag(ff.DV, ftrDV) | flag(ff.SV, ftrSV) | uint32(ff.SWO&0xF)<<ftrSWO
+	w |= flag(ff.FD, ftrFD) | flag(ff.EV, ftrEV) | uint32(ff.EBO&0x3F)<<ftrEBO
+	w |= flag(ff.RTSA, ftrRTSA) | flag(ff.RTSP, ftrRTSP)
+	w |= uint32(ff.TXC&0x1F) << ftrTXC
+	return Footer(WithParity(w))
+}
+
+func (f Footer) String() string {
+	var b strings.Builder
+	b.WriteString("ftr")
+	flags(&b, f.EXST(), "EXST")
+	flags(&b, f.HDRB(), "HDRB")
+	flags(&b, !f.Sync(), "!SYNC")
+	flags(&b, f.DV(), "DV")
+	if f.SV() {
+		b.WriteString(" SV@")
+		b.WriteString(strconv.Itoa(f.SWO() * 4))
+	}
+	if f.EV() {
+		b.WriteString(" EV@")
+		b.WriteString(strconv.Itoa(f.EBO()))
+	}
+	flags(&b, f.FD(), "FD")
+	flags(&b, f.RTSA(), "RTSA")
+	b.WriteString(" RCA=")
+	b.WriteString(strconv.Itoa(f.RCA()))
+	b.WriteString(" TXC=")
+	b.WriteString(strconv.Itoa(f.TXC()))
+	flags(&b, !f.ParityOK(), "BADPARITY")
+	return b.String()
+}
+
+func flag(v bool, pos uint) uint32 {
+	if v {
+		return 1 << pos
+	}
+	return 0
+}
+
+func flags(b *strings.Builder, v bool, name string) {
+	if v {
+		b.WriteByte(' ')
+		b.WriteString(name)
+	}
+}
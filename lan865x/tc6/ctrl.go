@@ -0,0 +1,157 @@
+package tc6
+
+import (
+	"errors"
+	"strconv"
+)
+
+// CtrlHeader is the header of a control transaction.
+type CtrlHeader uint32
+
+// Bit positions of control header fields.
+const (
+	ctlDNC  = 31
+	ctlHDRB = 30
+	ctlWNR  = 29
+	ctlAID  = 28
+	ctlMMS  = 24
+	ctlADDR = 8
+	ctlLEN  = 1
+)
+
+func (h CtrlHeader) DNC() bool  { return bit(uint32(h), ctlDNC) }
+func (h CtrlHeader) HDRB() bool { return bit(uint32(h), ctlHDRB) }
+
+// Write reports whether the transaction is a register write.
+func (h CtrlHeader) Write() bool { return bit(uint32(h), ctlWNR) }
+
+// AID reports whether address increment is disabled.
+func (h CtrlHeader) AID() bool { return bit(uint32(h), ctlAID) }
+
+// Addr returns the address of the first register accessed,
+// containing the memory map selector in bits 16..19.
+func (h CtrlHeader) Addr() uint32 {
+	return field(uint32(h), ctlMMS, 4)<<16 | field(uint32(h), ctlADDR, 16)
+}
+
+// NumRegs returns the number of registers accessed.
+func (h CtrlHeader) NumRegs() int { return int(field(uint32(h), ctlLEN, 7)) + 1 }
+
+func (h CtrlHeader) ParityOK() bool { return ParityOK(uint32(h)) }
+
+// MakeCtrlHeader returns a control header with the parity bit set.
+func MakeCtrlHeader(write, aid bool, addr uint32, numRegs int) CtrlHeader {
+	w := flag(write, ctlWNR) | flag(aid, ctlAID)
+	w |= (addr >> 16 & 0xF) << ctlMMS
+	w |= (addr & 0xFFFF) << ctlADDR
+	w |= uint32((numRegs-1)&0x7F) << ctlLEN
+	return CtrlHeader(WithParity(w))
+}
+
+func (h CtrlHeader) String() string {
+	s := "rd"
+	if h.Write() {
+		s = "wr"
+	}
+	s += " " + FormatAddr(h.Addr())
+	if n := h.NumRegs(); n != 1 {
+		s += " n=" + strconv.Itoa(n)
+	}
+	if h.AID() {
+		s += " AID"
+	}
+	if h.HDRB() {
+		s += " HDRB"
+	}
+	if !h.ParityOK() {
+		s += " BADPARITY"
+	}
+	return s
+}
+
+// CtrlXactLen returns the length of a control transaction
+// accessing numRegs registers.
+func CtrlXactLen(numRegs int, protected bool) int {
+	if protected {
+		numRegs *= 2
+	}
+	return 2*HeaderSize + 4*numRegs
+}
+
+var (
+	ErrXactLen    = errors.New("tc6: invalid transaction length")
+	ErrParity     = errors.New("tc6: header parity error")
+	ErrHeaderBad  = errors.New("tc6: header bad")
+	ErrEcho       = errors.New("tc6: echoed header differs")
+	ErrProtection = errors.New("tc6: protected value mismatch")
+	ErrSync       = errors.New("tc6: not synchronized")
+	ErrNoHardware = errors.New("tc6: no hardware")
+)
+
+// CtrlXact is a decoded control transaction.
+type CtrlXact struct {
+	Header    CtrlHeader
+	Echo      CtrlHeader
+	Protected bool
+
+	// Values contains the values written, in case of
+	// a write access, or the values read.
+	Values []uint32
+}
+
+// DecodeCtrl decodes a control transaction from the tx and rx buffers of
+// an SPI transfer. If an error is detected, a partially decoded
+// transaction is returned together with the error.
+func DecodeCtrl(tx, rx []byte) (*CtrlXact, error) {
+	if len(tx) < 2*HeaderSize || len(tx)%4 != 0 {
+		return nil, ErrXactLen
+	}
+	x := new(CtrlXact)
+	x.Header = CtrlHeader(Word(tx))
+	n := x.Header.NumRegs()
+	switch len(tx) {
+	case CtrlXactLen(n, false):
+	case CtrlXactLen(n, true):
+		x.Protected = true
+	default:
+		return x, ErrXactLen
+	}
+	if !x.Header.ParityOK() {
+		return x, ErrParity
+	}
+	data := tx[HeaderSize:]
+	if rx != nil {
+		if len(rx) != len(tx) {
+			return x, ErrXactLen
+		}
+		x.Echo = CtrlHeader(Word(rx[HeaderSize:]))
+		if !x.Header.Write() {
+			data = rx[2*HeaderSize:]
+		}
+	}
+	var err error
+	x.Values = make([]uint32, n)
+	for i := range x.Values {
+		if x.Protected {
+			v := Word(data[8*i:])
+			if v != ^Word(data[8*i+4:]) {
+				err = ErrProtection
+			}
+			x.Values[i] = v
+		} else {
+			x.Values[i] = Word(data[4*i:])
+		}
+	}
+	if rx == nil || err != nil {
+		return x, err
+	}
+	switch w := uint32(x.Echo); {
+	case w == 0, w == 0xFFFFFFFF:
+		err = ErrNoHardware
+	case x.Echo.HDRB():
+		err = ErrHeaderBad
+	case x.Echo != x.Header:
+		err = ErrEcho
+	}
+	return x, err
+}
@@ -0,0 +1,51 @@
+package tc6
+
+// Chunk is a decoded data chunk.
+type Chunk struct {
+	Header DataHeader
+	Footer Footer
+
+	// TxData and RxData refer to the payload parts of the
+	// tx and rx buffers.
+	TxData []byte
+	RxData []byte
+}
+
+// DecodeData decodes the data chunks of an SPI transfer.
+// The length of tx and rx must be a multiple of chunkSize + [HeaderSize].
+// Rx may be nil, in which case only the headers are decoded.
+func DecodeData(tx, rx []byte, chunkSize int) ([]Chunk, error) {
+	n := chunkSize + HeaderSize
+	if len(tx) == 0 || len(tx)%n != 0 || (rx != nil && len(rx) != len(tx)) {
+		return nil, ErrXactLen
+	}
+	chunks := make([]Chunk, len(tx)/n)
+	for i := range chunks {
+		c := &chunks[i]
+		t := tx[i*n:]
+		c.Header = DataHeader(Word(t))
+		c.TxData = t[HeaderSize:n]
+		if rx != nil {
+			r := rx[i*n:]
+			c.RxData = r[:chunkSize]
+			c.Footer = Footer(Word(r[chunkSize:]))
+		}
+	}
+	return chunks, nil
+}
+
+// Err returns an error if the footer indicates a problem,
+// as checked by the oa-tc6 library.
+func (f Footer) Err() error {
+	switch {
+	case f == 0, f == 0xFFFFFFFF:
+		return ErrNoHardware
+	case !f.ParityOK():
+		return ErrParity
+	case f.HDRB():
+		return ErrHeaderBad
+	case !f.Sync():
+		return ErrSync
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Package faultinj provides a wrapper around a [tc6.HwIntf] that injects
+// faults into SPI transfers and the interrupt line, to exercise
+// the error paths of a driver.
+package faultinj
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// Fault defines the kind of a fault to be injected.
+type Fault int
+
+const (
+	// FlipRxBit inverts a random bit of the received data.
+	FlipRxBit Fault = iota
+
+	// FlipFooterBit inverts a random bit of a data chunk footer
+	// without correcting the parity, resulting in a checksum error.
+	FlipFooterBit
+
+	// FlipFrameFlag inverts the DV, SV or EV flag of a data chunk footer,
+	// and corrects the parity, disturbing the reassembly of frames.
+	FlipFrameFlag
+
+	// ClearSync clears the SYNC flag in all footers of a transfer.
+	ClearSync
+
+	// SetHeaderBad sets the HDRB flag in all footers of a transfer.
+	SetHeaderBad
+
+	// SkipTransfer does not perform a transfer, but reports success,
+	// leaving an rx buffer containing zeroes, as if no hardware were attached.
+	SkipTransfer
+
+	// FailTransfer does not perform a transfer, and reports
+	// an error via the done callback.
+	FailTransfer
+
+	// RejectTransfer makes SpiTxRx return an error immediately.
+	RejectTransfer
+
+	// StallIntr lets the interrupt line appear inactive for
+	// the next Count calls of IntrActive.
+	StallIntr
+
+	// StuckIntr lets the interrupt line appear active for
+	// the next Count calls of IntrActive.
+	StuckIntr
+
+	// ChipReset calls Reset of the wrapped interface before
+	// the transfer is performed.
+	ChipReset
+
+	NumFaults
+)
+
+var faultNames = [NumFaults]string{
+	"FlipRxBit", "FlipFooterBit", "FlipFrameFlag", "ClearSync", "SetHeaderBad",
+	"SkipTransfer", "FailTransfer", "RejectTransfer", "StallIntr", "StuckIntr", "ChipReset",
+}
+
+func (f Fault) String() string {
+	if f < 0 || f >= NumFaults {
+		return "Fault?"
+	}
+	return faultNames[f]
+}
+
+// Target restricts a [Rule] to a kind of transfer.
+type Target int
+
+const (
+	AnyXfer Target = iota
+	CtrlXfer
+	DataXfer
+)
+
+// Rule defines when a fault is injected. Transfers are numbered
+// starting at 1. A rule triggers at transfer At, if non-zero, and then
+// every Every transfers, if non-zero. Additionally, if Prob is non-zero,
+// the rule triggers with probability Prob at each transfer.
+type Rule struct {
+	Fault  Fault
+	Target Target
+	At     int
+	Every  int
+	Prob   float64
+
+	// Count is the number of IntrActive calls affected
+	// by StallIntr and StuckIntr.
+	Count int
+}
+
+var ErrInjected = errors.New("faultinj: injected SPI failure")
+
+// Intf wraps a [tc6.HwIntf], injecting faults according to Rules.
+type Intf struct {
+	tc6.HwIntf
+	Rules []Rule
+
+	// ChunkSize is the chunk payload size. If zero,
+	// [tc6.DefaultChunkSize] is assumed.
+	ChunkSize int
+
+	// Rand is used for probabilities and bit selection. If nil,
+	// a source with a fixed seed is used, so that runs are reproducible.
+	Rand *rand.Rand
+
+	// OnInject, if not nil, is called for each fault injected.
+	OnInject func(f Fault, xfer int)
+
+	// Injected counts the faults injected per kind.
+	Injected [NumFaults]int
+
+	xfer      int
+	intrState bool
+	intrCount int
+}
+
+func (d *Intf) IntrActive() bool {
+	if d.intrCount > 0 {
+		d.intrCount--
+		return d.intrState
+	}
+	return d.HwIntf.IntrActive()
+}
+
+func (d *Intf) SpiTxRx(tx, rx []byte, done func(err error)) error {
+	d.xfer++
+	isData := len(tx) != 0 && tc6.IsData(tx)
+	var post []Fault
+	for i := range d.Rules {
+		r := &d.Rules[i]
+		if !d.triggers(r, isData) {
+			continue
+		}
+		f := r.Fault
+		switch f {
+		case RejectTransfer:
+			d.inject(f)
+			return ErrInjected
+		case FailTransfer:
+			d.inject(f)
+			done(ErrInjected)
+			return nil
+		case SkipTransfer:
+			d.inject(f)
+			clear(rx)
+			done(nil)
+			return nil
+		case StallIntr, StuckIntr:
+			d.inject(f)
+			d.intrState = f == StuckIntr
+			d.intrCount = r.Count
+		case ChipReset:
+			d.inject(f)
+			d.HwIntf.Reset()
+		default:
+			post = append(post, f)
+		}
+	}
+	if len(post) == 0 {
+		return d.HwIntf.SpiTxRx(tx, rx, done)
+	}
+	return d.HwIntf.SpiTxRx(tx, rx, func(err error) {
+		if err == nil {
+			for _, f := range post {
+				d.corrupt(f, rx, isData)
+			}
+		}
+		done(err)
+	})
+}
+
+func (d *Intf) triggers(r *Rule, isData bool) bool {
+	switch r.Target {
+	case CtrlXfer:
+		if isData {
+			return false
+		}
+	case DataXfer:
+		if !isData {
+			return false
+		}
+	}
+	n := d.xfer
+	if r.At != 0 && n >= r.At {
+		if n == r.At || (r.Every != 0 && (n-r.At)%r.Every == 0) {
+			return true
+		}
+	}
+	return r.Prob != 0 && d.rand().Float64() < r.Prob
+}
+
+func (d *Intf) corrupt(f Fault, rx []byte, isData bool) {
+	if len(rx) == 0 {
+		return
+	}
+	if f == FlipRxBit {
+		i := d.rand().Intn(len(rx) * 8)
+		rx[i/8] ^= 1 << (i % 8)
+		d.inject(f)
+		return
+	}
+	if !isData {
+		return
+	}
+	size := d.ChunkSize
+	if size == 0 {
+		size = tc6.DefaultChunkSize
+	}
+	n := size + tc6.HeaderSize
+	if len(rx)%n != 0 {
+		return
+	}
+	nChunks := len(rx) / n
+	switch f {
+	case FlipFooterBit:
+		ftr := rx[d.rand().Intn(nChunks)*n+size:]
+		i := d.rand().Intn(32)
+		ftr[i/8] ^= 1 << (i % 8)
+	case FlipFrameFlag:
+		ftr := rx[d.rand().Intn(nChunks)*n+size:]
+		flags := [...]tc6.Footer{tc6.FooterDV, tc6.FooterSV, tc6.FooterEV}
+		w := tc6.Word(ftr) ^ uint32(flags[d.rand().Intn(len(flags))])
+		tc6.PutWord(ftr, tc6.WithParity(w))
+	case ClearSync, SetHeaderBad:
+		for i := 0; i < nChunks; i++ {
+			ftr := rx[i*n+size:]
+			w := tc6.Footer(tc6.Word(ftr))
+			if f == ClearSync {
+				w &^= tc6.FooterSync
+			} else {
+				w |= tc6.FooterHDRB
+			}
+			tc6.PutWord(ftr, tc6.WithParity(uint32(w)))
+		}
+	default:
+		return
+	}
+	d.inject(f)
+}
+
+func (d *Intf) inject(f Fault) {
+	d.Injected[f]++
+	if d.OnInject != nil {
+		d.OnInject(f, d.xfer)
+	}
+}
+
+func (d *Intf) rand() *rand.Rand {
+	if d.Rand == nil {
+		d.Rand = rand.New(rand.NewSource(1))
+	}
+	return d.Rand
+}
+
+// Transfers returns the number of transfers requested so far;
+// it can be used to place a [Rule] relative to the current transfer.
+func (d *Intf) Transfers() int {
+	return d.xfer
+}
@@ -0,0 +1,200 @@
+package faultinj_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/emu"
+	"github.com/knieriem/t1s/lan865x/tc6/faultinj"
+)
+
+var (
+	dutAddr  = [6]byte{0x02, 0, 0, 0, 0, 1}
+	peerAddr = [6]byte{0x02, 0, 0, 0, 0, 2}
+)
+
+func frame(dst, src [6]byte) []byte {
+	f := make([]byte, 60)
+	copy(f, dst[:])
+	copy(f[6:], src[:])
+	f[12], f[13] = 0x88, 0xB5
+	return f
+}
+
+// upper transmits pending frames, and counts received ones.
+type upper struct {
+	pending int
+	rx      int
+}
+
+func (u *upper) SendEthUp(pkt []byte) error {
+	u.rx++
+	return nil
+}
+
+func (u *upper) PollForEth(buf []byte) (int, error) {
+	if u.pending == 0 {
+		return 0, nil
+	}
+	u.pending--
+	return copy(buf, frame(peerAddr, dutAddr)), nil
+}
+
+// harness runs a driver on an emulated MAC-PHY wrapped by
+// a faultinj.Intf, exchanging frames with a peer.
+type harness struct {
+	t      *testing.T
+	bus    *bussim.Bus
+	fi     *faultinj.Intf
+	inst   *lan865x.Inst
+	up     *upper
+	peer   *bussim.Port
+	peerRx int
+
+	protoErrs map[string]int
+}
+
+func newHarness(t *testing.T) *harness {
+	h := &harness{
+		t:         t,
+		bus:       bussim.New(1),
+		up:        new(upper),
+		protoErrs: make(map[string]int),
+	}
+	m := emu.New(h.bus, "dut", &emu.Conf{})
+	h.peer = h.bus.Attach("peer", func([]byte) { h.peerRx++ })
+	h.fi = &faultinj.Intf{HwIntf: m}
+	h.inst = &lan865x.Inst{
+		MAC:          &t1s.MACConf{Addr: dutAddr},
+		UpperProto:   h.up,
+		Dev:          h.fi,
+		Ticks:        h.bus,
+		OnProtoError: func(err lan865x.ProtoError) { h.protoErrs[err.Error()]++ },
+	}
+	t.Cleanup(h.inst.Close)
+	if err := h.inst.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// inject adds rule r, placed relative to the current transfer.
+func (h *harness) inject(r faultinj.Rule) {
+	r.At += h.fi.Transfers()
+	h.fi.Rules = append(h.fi.Rules, r)
+}
+
+// traffic services the driver for d, while a frame is
+// sent in each direction every interval.
+func (h *harness) traffic(d, interval time.Duration) {
+	for end := h.bus.Now() + d; h.bus.Now() < end; {
+		if h.bus.Now()%interval == 0 {
+			h.up.pending++
+			h.peer.Send(frame(dutAddr, peerAddr))
+		}
+		h.inst.Service()
+		h.bus.Advance(time.Millisecond)
+	}
+}
+
+// flows removes all rules, lets the driver settle, and checks
+// that frames are exchanged again in both directions.
+func (h *harness) flows() {
+	h.t.Helper()
+	h.fi.Rules = nil
+	h.traffic(200*time.Millisecond, 50*time.Millisecond)
+	rx, peerRx := h.up.rx, h.peerRx
+	h.traffic(100*time.Millisecond, 10*time.Millisecond)
+	if n := h.up.rx - rx; n != 10 {
+		h.t.Errorf("%d frames received after the faults, want 10", n)
+	}
+	if n := h.peerRx - peerRx; n != 10 {
+		h.t.Errorf("%d frames transmitted after the faults, want 10", n)
+	}
+}
+
+func (h *harness) wantProtoErr(text string) {
+	h.t.Helper()
+	if h.protoErrs[text] == 0 {
+		h.t.Errorf("no protocol error %q reported; got %v", text, h.protoErrs)
+	}
+}
+
+func (h *harness) injected(f faultinj.Fault) {
+	h.t.Helper()
+	if h.fi.Injected[f] == 0 {
+		h.t.Fatalf("%v not injected", f)
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	h := newHarness(t)
+	h.inject(faultinj.Rule{Fault: faultinj.FlipFooterBit, Target: faultinj.DataXfer, At: 1, Every: 2})
+	h.traffic(50*time.Millisecond, 5*time.Millisecond)
+	h.injected(faultinj.FlipFooterBit)
+	h.wantProtoErr("tc6: footer parity error")
+	h.flows()
+}
+
+func TestFrameFlagError(t *testing.T) {
+	h := newHarness(t)
+	h.inject(faultinj.Rule{Fault: faultinj.FlipFrameFlag, Target: faultinj.DataXfer, At: 1, Every: 3})
+	h.traffic(50*time.Millisecond, 5*time.Millisecond)
+	h.injected(faultinj.FlipFrameFlag)
+	if len(h.protoErrs) == 0 {
+		t.Error("no protocol errors reported")
+	}
+	h.flows()
+}
+
+func TestDroppedTransfer(t *testing.T) {
+	for _, tc := range []struct {
+		fault   faultinj.Fault
+		wantErr string
+	}{
+		{faultinj.FailTransfer, "tc6: SPI transfer failed"},
+		{faultinj.SkipTransfer, "tc6: no hardware"},
+		{faultinj.RejectTransfer, ""},
+	} {
+		t.Run(tc.fault.String(), func(t *testing.T) {
+			h := newHarness(t)
+			h.inject(faultinj.Rule{Fault: tc.fault, Target: faultinj.DataXfer, At: 1, Every: 4})
+			h.traffic(50*time.Millisecond, 5*time.Millisecond)
+			h.injected(tc.fault)
+			if tc.wantErr != "" {
+				h.wantProtoErr(tc.wantErr)
+			}
+			h.flows()
+		})
+	}
+}
+
+func TestChipReset(t *testing.T) {
+	h := newHarness(t)
+	h.inject(faultinj.Rule{Fault: faultinj.ChipReset, Target: faultinj.DataXfer, At: 1})
+	h.traffic(50*time.Millisecond, 5*time.Millisecond)
+	h.injected(faultinj.ChipReset)
+
+	// The MAC-PHY has lost its configuration; the driver
+	// detects the loss of sync, and reinitializes it.
+	h.wantProtoErr("tc6: sync lost")
+	if n := h.inst.Stats().Reinits; n != 1 {
+		t.Errorf("%d reinitializations, want 1", n)
+	}
+	h.flows()
+}
+
+func TestIntr(t *testing.T) {
+	for _, f := range []faultinj.Fault{faultinj.StuckIntr, faultinj.StallIntr} {
+		t.Run(f.String(), func(t *testing.T) {
+			h := newHarness(t)
+			h.inject(faultinj.Rule{Fault: f, At: 1, Count: 200})
+			h.traffic(100*time.Millisecond, 5*time.Millisecond)
+			h.injected(f)
+			h.flows()
+		})
+	}
+}
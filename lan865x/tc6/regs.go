@@ -0,0 +1,166 @@
+package tc6
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Memory map selectors.
+const (
+	MMSStd       = 0  // OPEN Alliance standard registers and PHY clause 22 registers
+	MMSMAC       = 1  // MAC registers
+	MMSPCS       = 2  // PHY PCS registers
+	MMSPMA       = 3  // PHY PMA/PMD registers
+	MMSPHYVendor = 4  // PHY vendor specific registers, including PLCA
+	MMSVendor    = 10 // Vendor specific registers
+)
+
+// Register addresses; bits 16..19 contain the memory map selector.
+const (
+	RegIDVer   = 0x00000000
+	RegPHYID   = 0x00000001
+	RegStdCap  = 0x00000002
+	RegReset   = 0x00000003
+	RegConfig0 = 0x00000004
+	RegConfig1 = 0x00000005
+	RegStatus0 = 0x00000008
+	RegStatus1 = 0x00000009
+	RegBufSts  = 0x0000000B
+	RegIMask0  = 0x0000000C
+	RegIMask1  = 0x0000000D
+
+	RegNetworkControl = 0x00010000
+	RegNetworkConfig  = 0x00010001
+	RegHashBottom     = 0x00010020
+	RegHashTop        = 0x00010021
+	RegSpecAddr1Bot   = 0x00010022
+	RegSpecAddr1Top   = 0x00010023
+	RegSpecAddr2Bot   = 0x00010024
+	RegSpecAddr2Top   = 0x00010025
+	RegSpecAddr3Bot   = 0x00010026
+	RegSpecAddr3Top   = 0x00010027
+	RegSpecAddr4Bot   = 0x00010028
+	RegSpecAddr4Top   = 0x00010029
+
+	RegDeepSleepCtrl0 = 0x00040080
+	RegDeepSleepCtrl1 = 0x00040081
+	RegColDetCtrl0    = 0x00040087
+	RegPLCACtrl0      = 0x0004CA01
+	RegPLCACtrl1      = 0x0004CA02
+	RegPLCAStatus     = 0x0004CA03
+	RegPLCATOTimer    = 0x0004CA04
+	RegPLCABurst      = 0x0004CA05
+
+	RegDevID = 0x000A0094
+)
+
+var regNames = map[uint32]string{
+	RegIDVer:   "OA_ID",
+	RegPHYID:   "OA_PHYID",
+	RegStdCap:  "OA_STDCAP",
+	RegReset:   "OA_RESET",
+	RegConfig0: "OA_CONFIG0",
+	RegConfig1: "OA_CONFIG1",
+	RegStatus0: "OA_STATUS0",
+	RegStatus1: "OA_STATUS1",
+	RegBufSts:  "OA_BUFSTS",
+	RegIMask0:  "OA_IMASK0",
+	RegIMask1:  "OA_IMASK1",
+
+	RegNetworkControl: "MAC_NCR",
+	RegNetworkConfig:  "MAC_NCFGR",
+	RegHashBottom:     "MAC_HRB",
+	RegHashTop:        "MAC_HRT",
+	RegSpecAddr1Bot:   "MAC_SAB1",
+	RegSpecAddr1Top:   "MAC_SAT1",
+	RegSpecAddr2Bot:   "MAC_SAB2",
+	RegSpecAddr2Top:   "MAC_SAT2",
+	RegSpecAddr3Bot:   "MAC_SAB3",
+	RegSpecAddr3Top:   "MAC_SAT3",
+	RegSpecAddr4Bot:   "MAC_SAB4",
+	RegSpecAddr4Top:   "MAC_SAT4",
+
+	RegDeepSleepCtrl0: "DEEP_SLEEP_CTRL_0",
+	RegDeepSleepCtrl1: "DEEP_SLEEP_CTRL_1",
+	RegColDetCtrl0:    "COL_DET_CTRL0",
+	RegPLCACtrl0:      "PLCA_CTRL0",
+	RegPLCACtrl1:      "PLCA_CTRL1",
+	RegPLCAStatus:     "PLCA_STS",
+	RegPLCATOTimer:    "PLCA_TOTMR",
+	RegPLCABurst:      "PLCA_BURST",
+
+	RegDevID: "DEVID",
+}
+
+// RegName returns the name of the register at addr,
+// or an empty string if the register is unknown.
+func RegName(addr uint32) string {
+	return regNames[addr]
+}
+
+// RegAddrs returns the addresses of all registers known
+// by name, in ascending order.
+func RegAddrs() []uint32 {
+	a := make([]uint32, 0, len(regNames))
+	for addr := range regNames {
+		a = append(a, addr)
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	return a
+}
+
+var ErrAddrSyntax = errors.New("tc6: invalid register address")
+
+// ParseAddr parses a register address, specified either by a register
+// name as returned by [RegName], as a number containing the memory
+// map selector in bits 16..19, or in the form mms:addr, as
+// produced by [FormatAddr].
+func ParseAddr(s string) (uint32, error) {
+	if i := strings.IndexByte(s, '('); i != -1 && strings.HasSuffix(s, ")") {
+		s = s[:i]
+	}
+	for addr, name := range regNames {
+		if strings.EqualFold(s, name) {
+			return addr, nil
+		}
+	}
+	mms, s, found := strings.Cut(s, ":")
+	if !found {
+		s = mms
+		mms = "0"
+	}
+	m, err := strconv.ParseUint(mms, 0, 4)
+	if err != nil {
+		return 0, ErrAddrSyntax
+	}
+	bits := 20
+	if found {
+		bits = 16
+	}
+	a, err := strconv.ParseUint(s, 0, bits)
+	if err != nil {
+		return 0, ErrAddrSyntax
+	}
+	return uint32(m)<<16 | uint32(a), nil
+}
+
+// FormatAddr returns a string representation of addr,
+// containing the memory map selector, the address, and
+// the register's name, if known.
+func FormatAddr(addr uint32) string {
+	s := strconv.FormatUint(uint64(addr>>16&0xF), 10) + ":" + hex(addr&0xFFFF, 4)
+	if name := RegName(addr); name != "" {
+		s += "(" + name + ")"
+	}
+	return s
+}
+
+func hex(v uint32, width int) string {
+	s := strconv.FormatUint(uint64(v), 16)
+	for len(s) < width {
+		s = "0" + s
+	}
+	return "0x" + s
+}
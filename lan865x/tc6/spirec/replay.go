@@ -0,0 +1,220 @@
+package spirec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Replayer implements [tc6.HwIntf], feeding the responses from a recording
+// back to a driver. Calls of the driver must occur in the same order as
+// recorded, and SPI transfers must carry the same tx data. Otherwise, the
+// replay stops, and the corresponding calls return an error, or false
+// in case of IntrActive. The error can be retrieved using [Replayer.Err].
+type Replayer struct {
+	events  []event
+	i       int
+	intrPos int
+	err     error
+}
+
+type event struct {
+	line   int
+	kind   byte
+	intr   bool
+	count  int
+	tx, rx []byte
+	err    error
+}
+
+var ErrEndOfRecording = errors.New("spirec: end of recording")
+
+// MismatchError reports a call that does not match the recording.
+type MismatchError struct {
+	Line int    // line of the recorded event
+	Call string // the call that has been made
+	Msg  string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("spirec: line %d: %s: %s", e.Line, e.Call, e.Msg)
+}
+
+// Load reads a recording.
+func Load(r io.Reader) (*Replayer, error) {
+	p := new(Replayer)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		ev, err := parseEvent(text)
+		if err != nil {
+			return nil, fmt.Errorf("spirec: line %d: %w", line, err)
+		}
+		ev.line = line
+		p.events = append(p.events, ev)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseEvent(text string) (ev event, err error) {
+	f, rest := fields(text, 3)
+	ev.kind = f[0][0]
+	if len(f[0]) != 1 {
+		return ev, errors.New("invalid event")
+	}
+	switch ev.kind {
+	case 'R':
+		rest = strings.TrimSpace(text[1:])
+	case 'I':
+		if len(f) < 2 {
+			return ev, errors.New("missing interrupt state")
+		}
+		ev.intr = f[1] == "1"
+		ev.count = 1
+		if len(f) > 2 {
+			ev.count, err = strconv.Atoi(f[2])
+			if err != nil {
+				return ev, err
+			}
+		}
+		rest = ""
+	case 'S':
+		if len(f) < 3 {
+			return ev, errors.New("missing spi data")
+		}
+		ev.tx, err = hex.DecodeString(f[1])
+		if err != nil {
+			return ev, err
+		}
+		ev.rx, err = hex.DecodeString(f[2])
+		if err != nil {
+			return ev, err
+		}
+	case 'F':
+		f, rest = fields(text, 2)
+		if len(f) < 2 || rest == "" {
+			return ev, errors.New("missing spi data or error")
+		}
+		ev.tx, err = hex.DecodeString(f[1])
+		if err != nil {
+			return ev, err
+		}
+	default:
+		return ev, errors.New("invalid event")
+	}
+	if rest != "" {
+		msg, err := strconv.Unquote(rest)
+		if err != nil {
+			return ev, err
+		}
+		ev.err = errors.New(msg)
+	}
+	return ev, nil
+}
+
+// fields splits the first n space separated fields from s,
+// and returns them together with the remaining text.
+func fields(s string, n int) (f []string, rest string) {
+	for len(f) < n {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+		i := strings.IndexAny(s, " \t")
+		if i == -1 {
+			i = len(s)
+		}
+		f = append(f, s[:i])
+		s = s[i:]
+	}
+	return f, strings.TrimSpace(s)
+}
+
+// next returns the next event, if it is of one of the given kinds.
+func (p *Replayer) next(kinds string, call string) *event {
+	if p.err != nil {
+		return nil
+	}
+	if p.i == len(p.events) {
+		p.err = ErrEndOfRecording
+		return nil
+	}
+	ev := &p.events[p.i]
+	if strings.IndexByte(kinds, ev.kind) == -1 {
+		p.mismatch(ev, call, "recorded event is "+string(ev.kind))
+		return nil
+	}
+	return ev
+}
+
+func (p *Replayer) mismatch(ev *event, call, msg string) {
+	p.err = &MismatchError{Line: ev.line, Call: call, Msg: msg}
+}
+
+func (p *Replayer) Reset() error {
+	ev := p.next("R", "Reset")
+	if ev == nil {
+		return p.err
+	}
+	p.i++
+	return ev.err
+}
+
+func (p *Replayer) IntrActive() bool {
+	ev := p.next("I", "IntrActive")
+	if ev == nil {
+		return false
+	}
+	p.intrPos++
+	if p.intrPos == ev.count {
+		p.intrPos = 0
+		p.i++
+	}
+	return ev.intr
+}
+
+func (p *Replayer) SpiTxRx(tx, rx []byte, done func(err error)) error {
+	ev := p.next("SF", "SpiTxRx")
+	if ev == nil {
+		return p.err
+	}
+	switch {
+	case !bytes.Equal(tx, ev.tx):
+		p.mismatch(ev, "SpiTxRx", fmt.Sprintf("tx differs: % x", tx))
+		return p.err
+	case ev.kind == 'F':
+		p.i++
+		return ev.err
+	case len(rx) != len(ev.rx):
+		p.mismatch(ev, "SpiTxRx", "rx length differs")
+		return p.err
+	}
+	p.i++
+	copy(rx, ev.rx)
+	done(ev.err)
+	return nil
+}
+
+// Err returns the reason why a replay stopped, if any.
+func (p *Replayer) Err() error {
+	return p.err
+}
+
+// Remaining returns the number of events not yet replayed.
+func (p *Replayer) Remaining() int {
+	return len(p.events) - p.i
+}
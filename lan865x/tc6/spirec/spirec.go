@@ -0,0 +1,105 @@
+// Package spirec records the interaction of a driver with a [tc6.HwIntf]
+// to a file, and replays such recordings deterministically.
+//
+// A recording is a text file with one event per line:
+//
+//	R [error]             Reset was called
+//	I 0|1 [count]         IntrActive returned false resp. true, count times in a row
+//	S <tx> <rx> [error]   SpiTxRx transferred tx and received rx (hex encoded)
+//	F <tx> error          SpiTxRx failed with error, without calling done
+//
+// Empty lines and lines starting with '#' are ignored.
+package spirec
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// Recorder wraps a [tc6.HwIntf], writing each call to a recording.
+type Recorder struct {
+	tc6.HwIntf
+
+	w   io.Writer
+	err error
+
+	intr      bool
+	intrCount int
+}
+
+// NewRecorder returns a Recorder for dev writing to w.
+// Each event is written as soon as it is complete, so that
+// a recording is usable even if a program terminates abruptly.
+func NewRecorder(dev tc6.HwIntf, w io.Writer) *Recorder {
+	r := &Recorder{HwIntf: dev, w: w}
+	r.printf("# tc6 spi recording\n")
+	return r
+}
+
+func (r *Recorder) Reset() error {
+	err := r.HwIntf.Reset()
+	r.flushIntr()
+	r.printf("R%s\n", errSuffix(err))
+	return err
+}
+
+func (r *Recorder) IntrActive() bool {
+	active := r.HwIntf.IntrActive()
+	if r.intrCount != 0 && active != r.intr {
+		r.flushIntr()
+	}
+	r.intr = active
+	r.intrCount++
+	return active
+}
+
+func (r *Recorder) SpiTxRx(tx, rx []byte, done func(err error)) error {
+	called := false
+	err := r.HwIntf.SpiTxRx(tx, rx, func(err error) {
+		called = true
+		r.flushIntr()
+		r.printf("S %x %x%s\n", tx, rx, errSuffix(err))
+		done(err)
+	})
+	if err != nil && !called {
+		r.flushIntr()
+		r.printf("F %x%s\n", tx, errSuffix(err))
+	}
+	return err
+}
+
+func (r *Recorder) flushIntr() {
+	if r.intrCount == 0 {
+		return
+	}
+	v := 0
+	if r.intr {
+		v = 1
+	}
+	r.printf("I %d %d\n", v, r.intrCount)
+	r.intrCount = 0
+}
+
+func (r *Recorder) printf(format string, a ...any) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = fmt.Fprintf(r.w, format, a...)
+}
+
+// Flush writes a pending interrupt line sample event,
+// and returns the first error that occurred while recording.
+func (r *Recorder) Flush() error {
+	r.flushIntr()
+	return r.err
+}
+
+func errSuffix(err error) string {
+	if err == nil {
+		return ""
+	}
+	return " " + strconv.Quote(err.Error())
+}
@@ -0,0 +1,162 @@
+package spirec_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/emu"
+	"github.com/knieriem/t1s/lan865x/tc6"
+	"github.com/knieriem/t1s/lan865x/tc6/faultinj"
+	"github.com/knieriem/t1s/lan865x/tc6/spirec"
+)
+
+var (
+	dutAddr  = [6]byte{0x02, 0, 0, 0, 0, 1}
+	peerAddr = [6]byte{0x02, 0, 0, 0, 0, 2}
+)
+
+// upper transmits a single frame, and collects received frames.
+type upper struct {
+	tx []byte
+	rx [][]byte
+}
+
+func (u *upper) SendEthUp(pkt []byte) error {
+	u.rx = append(u.rx, append([]byte(nil), pkt...))
+	return nil
+}
+
+func (u *upper) PollForEth(buf []byte) (int, error) {
+	n := copy(buf, u.tx)
+	u.tx = nil
+	return n, nil
+}
+
+func frame(dst, src [6]byte, payload string) []byte {
+	f := make([]byte, 60)
+	copy(f, dst[:])
+	copy(f[6:], src[:])
+	f[12], f[13] = 0x88, 0xB5
+	copy(f[14:], payload)
+	return f
+}
+
+// session initializes a driver using dev, lets it transmit
+// a frame containing payload, and services it for 50 ms.
+// Each millisecond, advance is called.
+func session(t *testing.T, dev tc6.HwIntf, ticks t1s.TicksProvider, advance func(), payload string) (*lan865x.Inst, *upper, error) {
+	t.Helper()
+	up := &upper{tx: frame(peerAddr, dutAddr, payload)}
+	inst := &lan865x.Inst{
+		MAC:        &t1s.MACConf{Addr: dutAddr},
+		UpperProto: up,
+		Dev:        dev,
+		Ticks:      ticks,
+		RxPolicy:   lan865x.RxPolicy{CheckFCS: true, StripFCS: true},
+	}
+	t.Cleanup(inst.Close)
+	if err := inst.Init(); err != nil {
+		return inst, up, err
+	}
+	for i := 0; i < 50; i++ {
+		inst.Service()
+		advance()
+	}
+	return inst, up, nil
+}
+
+// record records a session with an emulated MAC-PHY;
+// if rules are given, faults are injected.
+func record(t *testing.T, rules ...faultinj.Rule) []byte {
+	var buf bytes.Buffer
+	bus := bussim.New(1)
+	var m tc6.HwIntf = emu.New(bus, "dut", &emu.Conf{})
+	if rules != nil {
+		m = &faultinj.Intf{HwIntf: m, Rules: rules}
+	}
+	var peerRx [][]byte
+	peer := bus.Attach("peer", func(f []byte) {
+		peerRx = append(peerRx, append([]byte(nil), f...))
+	})
+	peer.Send(frame(dutAddr, peerAddr, "from peer"))
+
+	rec := spirec.NewRecorder(m, &buf)
+	inst, up, err := session(t, rec, bus, func() { bus.Advance(time.Millisecond) }, "from dut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.Close()
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(peerRx) != 1 || len(up.rx) != 1 {
+		t.Fatalf("recording: %d frames sent, %d received; want 1 each", len(peerRx), len(up.rx))
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	replay(t, record(t))
+}
+
+func TestRoundTripFailures(t *testing.T) {
+	rec := record(t,
+		faultinj.Rule{Fault: faultinj.RejectTransfer, At: 40},
+		faultinj.Rule{Fault: faultinj.FailTransfer, At: 50})
+	if !bytes.Contains(rec, []byte("\nF ")) {
+		t.Error("rejected transfer not recorded")
+	}
+	if !bytes.Contains(rec, []byte(strconv.Quote(faultinj.ErrInjected.Error())+"\nS ")) {
+		t.Error("failed transfer not recorded")
+	}
+	replay(t, rec)
+}
+
+// replay replays a recording made by record.
+func replay(t *testing.T, rec []byte) {
+	t.Helper()
+	r, err := spirec.Load(bytes.NewReader(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clock lan865x.VirtualClock
+	_, up, err := session(t, r, &clock, func() { clock.Advance(time.Millisecond) }, "from dut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Remaining(); n != 0 {
+		t.Errorf("%d events not replayed", n)
+	}
+	want := frame(dutAddr, peerAddr, "from peer")
+	if len(up.rx) != 1 || !bytes.Equal(up.rx[0], want) {
+		t.Errorf("received %x, want %x", up.rx, want)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	r, err := spirec.Load(bytes.NewReader(record(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clock lan865x.VirtualClock
+	_, _, err = session(t, r, &clock, func() { clock.Advance(time.Millisecond) }, "diverging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e *spirec.MismatchError
+	if !errors.As(r.Err(), &e) {
+		t.Fatalf("replay error: %v; want MismatchError", r.Err())
+	}
+	if e.Call != "SpiTxRx" {
+		t.Errorf("mismatch reported for %s, want SpiTxRx", e.Call)
+	}
+}
@@ -0,0 +1,169 @@
+// Package spitrace provides a wrapper around a [tc6.HwIntf]
+// that decodes SPI transfers and logs them in a human-readable form.
+package spitrace
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+// LevelTrace is the level used for logging the individual
+// chunks of data transfers.
+const LevelTrace = slog.LevelDebug - 4
+
+// Intf wraps a [tc6.HwIntf], logging decoded control transactions
+// and data transfers at debug level, individual chunks at [LevelTrace],
+// and protocol errors at warning level.
+type Intf struct {
+	tc6.HwIntf
+
+	// Log receives the messages; if nil, they are discarded.
+	Log *slog.Logger
+
+	// ChunkSize is the chunk payload size. If zero,
+	// [tc6.DefaultChunkSize] is assumed.
+	ChunkSize int
+}
+
+func (d *Intf) log() *slog.Logger {
+	if d.Log == nil {
+		return discard
+	}
+	return d.Log
+}
+
+var discard = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (h discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (h discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler        { return h }
+func (h discardHandler) WithGroup(string) slog.Handler             { return h }
+
+func (d *Intf) Reset() error {
+	err := d.HwIntf.Reset()
+	d.log().Debug("reset", "err", err)
+	return err
+}
+
+// Wake calls the Wake method of the wrapped interface, if it
+// implements [tc6.Waker]; otherwise [tc6.ErrNoWake] is returned.
+func (d *Intf) Wake() error {
+	w, ok := d.HwIntf.(tc6.Waker)
+	if !ok {
+		return tc6.ErrNoWake
+	}
+	err := w.Wake()
+	d.log().Debug("wake", "err", err)
+	return err
+}
+
+func (d *Intf) IntrActive() bool {
+	active := d.HwIntf.IntrActive()
+	if active {
+		d.log().Log(context.Background(), LevelTrace, "intr")
+	}
+	return active
+}
+
+func (d *Intf) SpiTxRx(tx, rx []byte, done func(err error)) error {
+	err := d.HwIntf.SpiTxRx(tx, rx, func(err error) {
+		if err != nil {
+			d.log().Warn("spi", "len", len(tx), "err", err)
+		} else {
+			d.trace(tx, rx)
+		}
+		done(err)
+	})
+	if err != nil {
+		d.log().Warn("spi", "len", len(tx), "err", err)
+	}
+	return err
+}
+
+func (d *Intf) trace(tx, rx []byte) {
+	if len(tx) < tc6.HeaderSize {
+		d.log().Warn("spi: short transfer", "len", len(tx))
+		return
+	}
+	if tc6.IsData(tx) {
+		d.traceData(tx, rx)
+	} else {
+		d.traceCtrl(tx, rx)
+	}
+}
+
+func (d *Intf) traceCtrl(tx, rx []byte) {
+	x, err := tc6.DecodeCtrl(tx, rx)
+	if x == nil {
+		d.log().Warn("ctrl", "len", len(tx), "err", err)
+		return
+	}
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	d.log().Log(context.Background(), level, "ctrl",
+		"op", x.Header,
+		"protected", x.Protected,
+		"values", hexValues(x.Values),
+		"err", err)
+}
+
+func (d *Intf) traceData(tx, rx []byte) {
+	size := d.ChunkSize
+	if size == 0 {
+		size = tc6.DefaultChunkSize
+	}
+	chunks, err := tc6.DecodeData(tx, rx, size)
+	if err != nil {
+		d.log().Warn("data", "len", len(tx), "err", err)
+		return
+	}
+	ctx := context.Background()
+	var nTx, nRx int
+	var errChunks int
+	for i := range chunks {
+		c := &chunks[i]
+		if c.Header.DV() {
+			nTx++
+		}
+		if c.Footer.DV() {
+			nRx++
+		}
+		ferr := c.Footer.Err()
+		if ferr != nil || !c.Header.ParityOK() {
+			errChunks++
+			d.log().Warn("chunk", "i", i, "tx", c.Header, "rx", c.Footer, "err", ferr)
+			continue
+		}
+		d.log().Log(ctx, LevelTrace, "chunk", "i", i, "tx", c.Header, "rx", c.Footer)
+	}
+	last := chunks[len(chunks)-1].Footer
+	d.log().Debug("data",
+		"chunks", len(chunks),
+		"txValid", nTx,
+		"rxValid", nRx,
+		"txc", last.TXC(),
+		"rca", last.RCA(),
+		"errors", errChunks)
+}
+
+type hexValues []uint32
+
+func (v hexValues) LogValue() slog.Value {
+	b := make([]byte, 0, len(v)*11)
+	for i, w := range v {
+		if i != 0 {
+			b = append(b, ' ')
+		}
+		b = append(b, "0x"...)
+		for s := 28; s >= 0; s -= 4 {
+			b = append(b, "0123456789abcdef"[w>>s&0xF])
+		}
+	}
+	return slog.StringValue(string(b))
+}
@@ -0,0 +1,59 @@
+package spitrace_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/emu"
+	"github.com/knieriem/t1s/lan865x/tc6/spitrace"
+)
+
+type upper struct{}
+
+func (upper) SendEthUp(pkt []byte) error         { return nil }
+func (upper) PollForEth(buf []byte) (int, error) { return 0, nil }
+
+// run initializes a driver on top of d, and services it for 20 ms.
+func run(t *testing.T, d *spitrace.Intf, bus *bussim.Bus) {
+	t.Helper()
+	inst := &lan865x.Inst{
+		MAC:        &t1s.MACConf{Addr: [6]byte{0x02, 0, 0, 0, 0, 1}},
+		UpperProto: upper{},
+		Dev:        d,
+		Ticks:      bus,
+	}
+	defer inst.Close()
+	if err := inst.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		inst.Service()
+		bus.Advance(time.Millisecond)
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	bus := bussim.New(1)
+	d := &spitrace.Intf{
+		HwIntf: emu.New(bus, "dut", &emu.Conf{}),
+		Log:    slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: spitrace.LevelTrace})),
+	}
+	run(t, d, bus)
+	for _, s := range []string{"msg=ctrl", "OA_STATUS0", "msg=chunk"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("%q not logged", s)
+		}
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	bus := bussim.New(1)
+	run(t, &spitrace.Intf{HwIntf: emu.New(bus, "dut", &emu.Conf{})}, bus)
+}
@@ -0,0 +1,398 @@
+// Package spitunnel tunnels a [tc6.HwIntf] over a stream connection,
+// like TCP. A [Server] exposes a local hardware interface, for instance
+// an SPI device and GPIO pins on a Raspberry Pi, and a [Client],
+// connected to the server, implements tc6.HwIntf. This way a driver
+// may run on a workstation, while the MAC-PHY is attached to a remote
+// machine.
+//
+// After a handshake, the client sends requests, each answered
+// by the server with a response:
+//
+//	request:  op[1] (op 'S': len[4] tx[len])
+//	response: status[1] (status != 0: len[2] msg[len])
+//	          (op 'S', status 0: rx[len]; op 'I', status 0: active[1])
+//
+// Op 'S' performs an SPI transfer, 'I' queries the interrupt line,
+// and 'R' resets the MAC-PHY. Lengths are stored in network byte order.
+package spitunnel
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/knieriem/t1s/lan865x/tc6"
+)
+
+const (
+	magic   = "T1ST"
+	version = 1
+
+	// MaxTransferLen is the maximum length of an SPI transfer.
+	MaxTransferLen = 1 << 16
+)
+
+const (
+	opSpiTxRx     = 'S'
+	opIntrActive  = 'I'
+	opReset       = 'R'
+	statusOK      = 0
+	statusCallErr = 1 // the call returned an error
+	statusDoneErr = 2 // the transfer failed, reported via done
+)
+
+var (
+	ErrHandshake   = errors.New("spitunnel: handshake failed")
+	ErrBusy        = errors.New("spitunnel: server busy")
+	ErrProtocol    = errors.New("spitunnel: protocol error")
+	ErrTransferLen = errors.New("spitunnel: transfer too long")
+)
+
+// RemoteError is an error that occurred at the server.
+type RemoteError struct {
+	Msg string
+}
+
+func (e *RemoteError) Error() string {
+	return "spitunnel: remote: " + e.Msg
+}
+
+// Server exposes a tc6.HwIntf to clients. Only one
+// client at a time may use the interface; further
+// clients are rejected during the handshake.
+type Server struct {
+	Dev tc6.HwIntf
+
+	mu   sync.Mutex
+	busy bool
+}
+
+// Serve accepts connections on l, and serves each
+// of them in a separate goroutine. Errors of individual
+// connections are not reported.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			s.ServeConn(c)
+			c.Close()
+		}()
+	}
+}
+
+// ServeConn performs the handshake, and serves requests
+// received on c, until the connection is closed by the client.
+func (s *Server) ServeConn(c io.ReadWriter) error {
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	var hdr [len(magic) + 1]byte
+	_, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return err
+	}
+	if string(hdr[:len(magic)]) != magic || hdr[len(magic)] != version {
+		return ErrHandshake
+	}
+	w.Write(hdr[:])
+
+	s.mu.Lock()
+	busy := s.busy
+	s.busy = true
+	s.mu.Unlock()
+	if busy {
+		writeStatus(w, statusCallErr, ErrBusy)
+		w.Flush()
+		return ErrBusy
+	}
+	defer func() {
+		s.mu.Lock()
+		s.busy = false
+		s.mu.Unlock()
+	}()
+	writeStatus(w, statusOK, nil)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch op {
+		case opSpiTxRx:
+			var lb [4]byte
+			if _, err := io.ReadFull(r, lb[:]); err != nil {
+				return err
+			}
+			n := binary.BigEndian.Uint32(lb[:])
+			if n > MaxTransferLen {
+				return ErrTransferLen
+			}
+			if cap(buf) < int(2*n) {
+				buf = make([]byte, 2*n)
+			}
+			tx := buf[:n]
+			rx := buf[n : 2*n]
+			if _, err := io.ReadFull(r, tx); err != nil {
+				return err
+			}
+			if err := s.spiTxRx(tx, rx); err != nil {
+				writeErr(w, err)
+			} else {
+				writeStatus(w, statusOK, nil)
+				w.Write(rx)
+			}
+		case opIntrActive:
+			writeStatus(w, statusOK, nil)
+			active := byte(0)
+			if s.Dev.IntrActive() {
+				active = 1
+			}
+			w.WriteByte(active)
+		case opReset:
+			if err := s.Dev.Reset(); err != nil {
+				writeStatus(w, statusCallErr, err)
+			} else {
+				writeStatus(w, statusOK, nil)
+			}
+		default:
+			return ErrProtocol
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+type doneError struct {
+	err error
+}
+
+func (e *doneError) Error() string {
+	return e.err.Error()
+}
+
+func (s *Server) spiTxRx(tx, rx []byte) error {
+	ch := make(chan error, 1)
+	err := s.Dev.SpiTxRx(tx, rx, func(err error) {
+		ch <- err
+	})
+	if err != nil {
+		return err
+	}
+	if err := <-ch; err != nil {
+		return &doneError{err}
+	}
+	return nil
+}
+
+func writeErr(w *bufio.Writer, err error) {
+	if de, ok := err.(*doneError); ok {
+		writeStatus(w, statusDoneErr, de.err)
+		return
+	}
+	writeStatus(w, statusCallErr, err)
+}
+
+func writeStatus(w *bufio.Writer, status byte, err error) {
+	w.WriteByte(status)
+	if status == statusOK {
+		return
+	}
+	msg := err.Error()
+	if len(msg) > 0xFFFF {
+		msg = msg[:0xFFFF]
+	}
+	var lb [2]byte
+	binary.BigEndian.PutUint16(lb[:], uint16(len(msg)))
+	w.Write(lb[:])
+	w.WriteString(msg)
+}
+
+// Client implements tc6.HwIntf, forwarding calls
+// to a Server. The done callback of SpiTxRx is called
+// before SpiTxRx returns.
+type Client struct {
+	mu   sync.Mutex
+	conn io.ReadWriteCloser
+	r    *bufio.Reader
+	w    *bufio.Writer
+	err  error
+}
+
+// Dial connects to a server at the TCP address addr.
+func Dial(addr string) (*Client, error) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := NewClient(c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return cl, nil
+}
+
+// NewClient performs the handshake with a server connected via conn.
+func NewClient(conn io.ReadWriteCloser) (*Client, error) {
+	c := &Client{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	c.w.WriteString(magic)
+	c.w.WriteByte(version)
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	var hdr [len(magic) + 1]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if string(hdr[:len(magic)]) != magic || hdr[len(magic)] != version {
+		return nil, ErrHandshake
+	}
+	status, err := c.readStatus()
+	if err != nil {
+		return nil, err
+	}
+	if status != nil {
+		if status.Msg == ErrBusy.Error() {
+			return nil, ErrBusy
+		}
+		return nil, &status.RemoteError
+	}
+	return c, nil
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Err returns the first connection error that occurred.
+// After a connection error, all calls fail.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) Reset() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.call(opReset, nil)
+	if err != nil {
+		return err
+	}
+	if st != nil {
+		return &st.RemoteError
+	}
+	return nil
+}
+
+// IntrActive returns false in case of a connection error.
+func (c *Client) IntrActive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.call(opIntrActive, nil)
+	if err != nil || st != nil {
+		return false
+	}
+	b, err := c.r.ReadByte()
+	if err != nil {
+		c.err = err
+		return false
+	}
+	return b != 0
+}
+
+func (c *Client) SpiTxRx(tx, rx []byte, done func(err error)) error {
+	if len(tx) > MaxTransferLen {
+		return ErrTransferLen
+	}
+	if len(rx) < len(tx) {
+		return io.ErrShortBuffer
+	}
+	c.mu.Lock()
+	st, err := c.call(opSpiTxRx, tx)
+	if err == nil && st == nil {
+		_, err = io.ReadFull(c.r, rx[:len(tx)])
+		if err != nil {
+			c.err = err
+		}
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if st != nil {
+		if st.done {
+			done(&st.RemoteError)
+			return nil
+		}
+		return &st.RemoteError
+	}
+	done(nil)
+	return nil
+}
+
+type statusError struct {
+	RemoteError
+	done bool
+}
+
+// call sends a request, and reads the response status.
+// If the remote call failed, a non-nil *statusError is returned.
+func (c *Client) call(op byte, tx []byte) (*statusError, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.w.WriteByte(op)
+	if op == opSpiTxRx {
+		var lb [4]byte
+		binary.BigEndian.PutUint32(lb[:], uint32(len(tx)))
+		c.w.Write(lb[:])
+		c.w.Write(tx)
+	}
+	err := c.w.Flush()
+	if err == nil {
+		var st *statusError
+		st, err = c.readStatus()
+		if err == nil {
+			return st, nil
+		}
+	}
+	c.err = err
+	return nil, err
+}
+
+func (c *Client) readStatus() (*statusError, error) {
+	status, err := c.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case statusOK:
+		return nil, nil
+	case statusCallErr, statusDoneErr:
+	default:
+		return nil, ErrProtocol
+	}
+	var lb [2]byte
+	if _, err := io.ReadFull(c.r, lb[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(lb[:]))
+	if _, err := io.ReadFull(c.r, msg); err != nil {
+		return nil, err
+	}
+	return &statusError{RemoteError: RemoteError{Msg: string(msg)}, done: status == statusDoneErr}, nil
+}
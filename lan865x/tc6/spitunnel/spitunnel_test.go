@@ -0,0 +1,104 @@
+package spitunnel_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+	"github.com/knieriem/t1s/lan865x"
+	"github.com/knieriem/t1s/lan865x/emu"
+	"github.com/knieriem/t1s/lan865x/tc6"
+	"github.com/knieriem/t1s/lan865x/tc6/spitunnel"
+)
+
+type upper struct {
+	tx []byte
+}
+
+func (u *upper) SendEthUp(pkt []byte) error {
+	return nil
+}
+
+func (u *upper) PollForEth(buf []byte) (int, error) {
+	n := copy(buf, u.tx)
+	u.tx = nil
+	return n, nil
+}
+
+func TestLoopback(t *testing.T) {
+	bus := bussim.New(1)
+	m := emu.New(bus, "dut", &emu.Conf{})
+	var (
+		mu     sync.Mutex
+		peerRx [][]byte
+	)
+	bus.Attach("peer", func(f []byte) {
+		mu.Lock()
+		peerRx = append(peerRx, append([]byte(nil), f...))
+		mu.Unlock()
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := &spitunnel.Server{Dev: m}
+	go srv.Serve(l)
+
+	c, err := spitunnel.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Only one client at a time is served.
+	c2, err := spitunnel.Dial(l.Addr().String())
+	if !errors.Is(err, spitunnel.ErrBusy) {
+		if err == nil {
+			c2.Close()
+		}
+		t.Fatalf("second client: %v; want ErrBusy", err)
+	}
+
+	frame := make([]byte, 60)
+	copy(frame, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02, 0, 0, 0, 0, 1, 0x88, 0xB5})
+	inst := &lan865x.Inst{
+		MAC:        &t1s.MACConf{Addr: [6]byte{0x02, 0, 0, 0, 0, 1}},
+		PLCA:       &t1s.PLCAConf{NodeID: 0, NodeCount: 2},
+		UpperProto: &upper{tx: frame},
+		Dev:        c,
+		Ticks:      bus,
+	}
+	defer inst.Close()
+	if err := inst.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := inst.ChipInfo(); !got.Supported() {
+		t.Fatalf("chip info: %+v", got)
+	}
+	v, err := inst.ReadReg(tc6.RegPLCACtrl1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0x0200 {
+		t.Errorf("PLCA_CTRL1: %#x, want 0x200", v)
+	}
+	for i := 0; i < 50; i++ {
+		inst.Service()
+		bus.Advance(time.Millisecond)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(peerRx) != 1 || !bytes.Equal(peerRx[0], frame) {
+		t.Errorf("peer received %x, want %x", peerRx, frame)
+	}
+}
@@ -0,0 +1,85 @@
+// Package tc6 contains definitions of the OPEN Alliance
+// 10BASE-T1x MAC-PHY Serial Interface (TC6) protocol,
+// and functions for decoding SPI transfers.
+//
+// The package is independent of cgo, so that tools wrapping
+// or emulating a hardware interface may use it.
+package tc6
+
+import "errors"
+
+// HwIntf defines the hardware interface of a MAC-PHY:
+// a reset line, an interrupt line, and an SPI device.
+type HwIntf interface {
+	Reset() error
+	IntrActive() bool
+	SpiTxRx(tx, rx []byte, done func(err error)) error
+}
+
+// Waker may be implemented by a HwIntf that is able to wake up
+// a sleeping MAC-PHY, for instance by pulsing its WAKE_IN pin.
+// Wake returns ErrNoWake if, depending on the configuration,
+// there is no means to wake up the MAC-PHY.
+type Waker interface {
+	Wake() error
+}
+
+var ErrNoWake = errors.New("tc6: no means to wake up the MAC-PHY")
+
+// HeaderSize is the size of headers and footers of
+// data chunks and control transactions.
+const HeaderSize = 4
+
+// DefaultChunkSize is the data chunk payload size used on default.
+const DefaultChunkSize = 64
+
+// Word returns the 32-bit value stored in b in network byte order.
+func Word(b []byte) uint32 {
+	_ = b[3]
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// PutWord stores v in b in network byte order.
+func PutWord(b []byte, v uint32) {
+	_ = b[3]
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+}
+
+// ParityOK reports whether w, a header or footer including
+// its parity bit, has odd parity.
+func ParityOK(w uint32) bool {
+	return parity(w) == 1
+}
+
+// WithParity returns w with the parity bit set, so that
+// the resulting word has odd parity.
+func WithParity(w uint32) uint32 {
+	w &^= 1
+	return w | (parity(w) ^ 1)
+}
+
+func parity(w uint32) uint32 {
+	w ^= w >> 16
+	w ^= w >> 8
+	w ^= w >> 4
+	w ^= w >> 2
+	w ^= w >> 1
+	return w & 1
+}
+
+func bit(w uint32, pos uint) bool {
+	return w&(1<<pos) != 0
+}
+
+func field(w uint32, pos, width uint) uint32 {
+	return (w >> pos) & (1<<width - 1)
+}
+
+// IsData reports whether a header, as stored in the first byte of b,
+// belongs to a data chunk rather than a control transaction.
+func IsData(b []byte) bool {
+	return b[0]&0x80 != 0
+}
@@ -0,0 +1,237 @@
+package tc6
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParity(t *testing.T) {
+	for _, w := range []uint32{0, 1, 0x80000000, 0x24CA0200, 0xFFFFFFFE, 0x12345678} {
+		p := WithParity(w)
+		if !ParityOK(p) {
+			t.Errorf("WithParity(%#08x) = %#08x: parity not ok", w, p)
+		}
+		if p&^1 != w&^1 {
+			t.Errorf("WithParity(%#08x) = %#08x: changed other bits", w, p)
+		}
+		if ParityOK(p ^ 1) {
+			t.Errorf("%#08x: parity ok after flipping a bit", p^1)
+		}
+	}
+}
+
+func TestCtrlHeader(t *testing.T) {
+	for _, tc := range []struct {
+		w        uint32
+		write    bool
+		addr     uint32
+		n        int
+		parityOK bool
+		str      string
+	}{
+		{0x00000800, false, RegStatus0, 1, true, "rd 0:0x0008(OA_STATUS0)"},
+		{0x24CA0200, true, RegPLCACtrl1, 1, true, "wr 4:0xca02(PLCA_CTRL1)"},
+		{0x01000006, false, 0x10000, 4, true, "rd 1:0x0000(MAC_NCR) n=4"},
+		{0x24CA0201, true, RegPLCACtrl1, 1, false, "wr 4:0xca02(PLCA_CTRL1) BADPARITY"},
+		{0x40000801, false, RegStatus0, 1, true, "rd 0:0x0008(OA_STATUS0) HDRB"},
+	} {
+		h := CtrlHeader(tc.w)
+		if h.Write() != tc.write || h.Addr() != tc.addr || h.NumRegs() != tc.n || h.ParityOK() != tc.parityOK {
+			t.Errorf("%#08x: decoded as write %v, addr %#x, n %d, parity ok %v",
+				tc.w, h.Write(), h.Addr(), h.NumRegs(), h.ParityOK())
+		}
+		if s := h.String(); s != tc.str {
+			t.Errorf("%#08x: %q, want %q", tc.w, s, tc.str)
+		}
+		if tc.parityOK && !h.HDRB() {
+			if m := MakeCtrlHeader(tc.write, false, tc.addr, tc.n); m != h {
+				t.Errorf("MakeCtrlHeader: %#08x, want %#08x", uint32(m), tc.w)
+			}
+		}
+	}
+}
+
+func TestDataHeader(t *testing.T) {
+	for _, tc := range []struct {
+		w        uint32
+		dv, sv   bool
+		swo      int
+		ev       bool
+		ebo      int
+		noRX     bool
+		parityOK bool
+	}{
+		{0x80000000, false, false, 0, false, 0, false, true},
+		{0xA0000001, false, false, 0, false, 0, true, true},
+		{0x80300000, true, true, 0, false, 0, false, true},
+		{0x80207F00, true, false, 0, true, 63, false, true},
+		{0x80334A01, true, true, 3, true, 10, false, true},
+		{0x80300001, true, true, 0, false, 0, false, false},
+	} {
+		h := DataHeader(tc.w)
+		if !h.DNC() || h.DV() != tc.dv || h.SV() != tc.sv || h.SWO() != tc.swo ||
+			h.EV() != tc.ev || h.EBO() != tc.ebo || h.NoRX() != tc.noRX || h.ParityOK() != tc.parityOK {
+			t.Errorf("%#08x: decoded as %v", tc.w, h)
+		}
+		if tc.parityOK {
+			if m := MakeDataHeader(false, tc.noRX, tc.dv, tc.sv, tc.swo, tc.ev, tc.ebo); m != h {
+				t.Errorf("MakeDataHeader: %#08x, want %#08x", uint32(m), tc.w)
+			}
+		}
+	}
+}
+
+func TestFooter(t *testing.T) {
+	for _, tc := range []struct {
+		w   uint32
+		ff  FooterFields
+		err error
+	}{
+		{0x00000000, FooterFields{}, ErrNoHardware},
+		{0xFFFFFFFF, FooterFields{}, ErrNoHardware},
+		{0x20000000, FooterFields{Sync: true}, nil},
+		{0x2500003F, FooterFields{Sync: true, RCA: 5, TXC: 31}, nil},
+		{0x20324A00, FooterFields{Sync: true, DV: true, SV: true, SWO: 2, EV: true, EBO: 10}, nil},
+		{0xA0008000, FooterFields{EXST: true, Sync: true, FD: true}, nil},
+		{0x60000001, FooterFields{HDRB: true, Sync: true}, ErrHeaderBad},
+		{0x0000003E, FooterFields{TXC: 31}, ErrSync},
+		{0x20000001, FooterFields{Sync: true}, ErrParity},
+	} {
+		f := Footer(tc.w)
+		if err := f.Err(); err != tc.err {
+			t.Errorf("%#08x: error %v, want %v", tc.w, err, tc.err)
+		}
+		if tc.err == ErrNoHardware {
+			continue
+		}
+		got := FooterFields{
+			EXST: f.EXST(), HDRB: f.HDRB(), Sync: f.Sync(),
+			RCA: f.RCA(), DV: f.DV(), SV: f.SV(), SWO: f.SWO(),
+			FD: f.FD(), EV: f.EV(), EBO: f.EBO(), TXC: f.TXC(),
+		}
+		if got != tc.ff {
+			t.Errorf("%#08x: decoded as %+v, want %+v", tc.w, got, tc.ff)
+		}
+		if tc.err != ErrParity {
+			if m := MakeFooter(&tc.ff); m != f {
+				t.Errorf("MakeFooter(%+v): %#08x, want %#08x", tc.ff, uint32(m), tc.w)
+			}
+		}
+	}
+}
+
+func words(w ...uint32) []byte {
+	b := make([]byte, 4*len(w))
+	for i, v := range w {
+		PutWord(b[4*i:], v)
+	}
+	return b
+}
+
+func TestDecodeCtrl(t *testing.T) {
+	rdStatus0 := uint32(MakeCtrlHeader(false, false, RegStatus0, 1))
+	wrCtrl1 := uint32(MakeCtrlHeader(true, false, RegPLCACtrl1, 1))
+	rd2 := uint32(MakeCtrlHeader(false, false, RegStatus0, 2))
+	for _, tc := range []struct {
+		name      string
+		tx, rx    []byte
+		protected bool
+		values    []uint32
+		err       error
+	}{
+		{"read", words(rdStatus0, 0, 0), words(0, rdStatus0, 0x40), false, []uint32{0x40}, nil},
+		{"read2", words(rd2, 0, 0, 0), words(0, rd2, 0x40, 0x2), false, []uint32{0x40, 0x2}, nil},
+		{"write", words(wrCtrl1, 0x0803, 0), words(0, wrCtrl1, 0x0803), false, []uint32{0x0803}, nil},
+		{"write protected", words(wrCtrl1, 0x0803, ^uint32(0x0803), 0), words(0, wrCtrl1, 0x0803, ^uint32(0x0803)), true, []uint32{0x0803}, nil},
+		{"read protected", words(rdStatus0, 0, 0, 0), words(0, rdStatus0, 0x40, ^uint32(0x40)), true, []uint32{0x40}, nil},
+		{"protection", words(wrCtrl1, 0x0803, 0x0803, 0), words(0, wrCtrl1, 0, 0), true, []uint32{0x0803}, ErrProtection},
+		{"no hardware", words(rdStatus0, 0, 0), words(0, 0, 0), false, []uint32{0}, ErrNoHardware},
+		{"header bad", words(rdStatus0, 0, 0), words(0, rdStatus0|1<<ctlHDRB, 0), false, []uint32{0}, ErrHeaderBad},
+		{"echo", words(rdStatus0, 0, 0), words(0, wrCtrl1, 0), false, []uint32{0}, ErrEcho},
+		{"parity", words(rdStatus0^1, 0, 0), words(0, rdStatus0^1, 0), false, nil, ErrParity},
+		{"length", words(rdStatus0, 0, 0, 0, 0), nil, false, nil, ErrXactLen},
+		{"short", words(rdStatus0), nil, false, nil, ErrXactLen},
+	} {
+		x, err := DecodeCtrl(tc.tx, tc.rx)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if tc.values == nil {
+			continue
+		}
+		if x.Protected != tc.protected || len(x.Values) != len(tc.values) {
+			t.Errorf("%s: decoded as %+v", tc.name, x)
+			continue
+		}
+		for i, v := range tc.values {
+			if x.Values[i] != v {
+				t.Errorf("%s: value %d: %#x, want %#x", tc.name, i, x.Values[i], v)
+			}
+		}
+	}
+}
+
+func TestDecodeData(t *testing.T) {
+	const size = 64
+	hdr := []uint32{
+		uint32(MakeDataHeader(false, false, true, true, 0, false, 0)),
+		uint32(MakeDataHeader(true, false, true, false, 0, true, 13)),
+	}
+	ftr := []uint32{0x20000000, 0x2500003F}
+	tx := make([]byte, 2*(size+HeaderSize))
+	rx := make([]byte, len(tx))
+	for i := range hdr {
+		PutWord(tx[i*(size+HeaderSize):], hdr[i])
+		PutWord(rx[i*(size+HeaderSize)+size:], ftr[i])
+		rx[i*(size+HeaderSize)] = byte(i + 1)
+	}
+	chunks, err := DecodeData(tx, rx, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("%d chunks, want 2", len(chunks))
+	}
+	for i, c := range chunks {
+		if uint32(c.Header) != hdr[i] || uint32(c.Footer) != ftr[i] {
+			t.Errorf("chunk %d: %v, %v", i, c.Header, c.Footer)
+		}
+		if len(c.TxData) != size || len(c.RxData) != size || c.RxData[0] != byte(i+1) {
+			t.Errorf("chunk %d: payload not extracted", i)
+		}
+	}
+	if _, err := DecodeData(tx[:size], rx[:size], size); err != ErrXactLen {
+		t.Errorf("short transfer: error %v, want ErrXactLen", err)
+	}
+	if _, err := DecodeData(tx, rx[:size+HeaderSize], size); err != ErrXactLen {
+		t.Errorf("rx length mismatch: error %v, want ErrXactLen", err)
+	}
+}
+
+func TestAddr(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		addr uint32
+	}{
+		{"OA_STATUS0", RegStatus0},
+		{"plca_ctrl1", RegPLCACtrl1},
+		{"4:0xca02", RegPLCACtrl1},
+		{"4:0xca02(PLCA_CTRL1)", RegPLCACtrl1},
+		{"0x4ca02", RegPLCACtrl1},
+		{"1:1", 0x10001},
+	} {
+		addr, err := ParseAddr(tc.s)
+		if err != nil || addr != tc.addr {
+			t.Errorf("ParseAddr(%q) = %#x, %v; want %#x", tc.s, addr, err, tc.addr)
+		}
+	}
+	for _, s := range []string{"", "nosuchreg", "16:0", "1:0x10000", "0x100000"} {
+		if _, err := ParseAddr(s); err != ErrAddrSyntax {
+			t.Errorf("ParseAddr(%q): error %v, want ErrAddrSyntax", s, err)
+		}
+	}
+	if s := FormatAddr(RegPLCACtrl1); s != "4:0xca02(PLCA_CTRL1)" {
+		t.Errorf("FormatAddr: %q", s)
+	}
+}
@@ -0,0 +1,67 @@
+package lan865x
+
+import (
+	"sync"
+	"time"
+
+	"github.com/knieriem/t1s"
+)
+
+// Ticks must be set to an actual implementation of [TicksProvider]
+// before a driver can be initialized, unless each instance
+// has its own time base configured in [Inst.Ticks].
+var Ticks TicksProvider
+
+// TicksProvider provides a millisecond tick count. It is used for
+// timeouts of the oa-tc6 library, like the delay after which
+// extended status reporting is unlocked again.
+type TicksProvider = t1s.TicksProvider
+
+func (inst *Inst) ticks() TicksProvider {
+	if inst.Ticks != nil {
+		return inst.Ticks
+	}
+	return Ticks
+}
+
+// TicksFunc adapts a function to the [TicksProvider] interface.
+type TicksFunc func() uint32
+
+func (f TicksFunc) Milliseconds() uint32 {
+	return f()
+}
+
+// VirtualClock is a [TicksProvider] whose time advances only
+// if requested, so that timer dependent behavior can be tested
+// deterministically, without sleeping. It is safe for concurrent use.
+// The zero value is a clock at time zero.
+type VirtualClock struct {
+	mu  sync.Mutex
+	now time.Duration
+}
+
+// Milliseconds returns the current time of the clock in milliseconds.
+func (c *VirtualClock) Milliseconds() uint32 {
+	return uint32(c.Now() / time.Millisecond)
+}
+
+// Now returns the time elapsed since the start of the clock.
+func (c *VirtualClock) Now() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	c.mu.Unlock()
+}
+
+// Set sets the clock to time t.
+func (c *VirtualClock) Set(t time.Duration) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
@@ -0,0 +1,268 @@
+package lan865x
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/knieriem/t1s"
+)
+
+// DefaultTxQueueLen is the number of transmit buffers, of MTU bytes
+// each, used if Inst.TxQueueLen is zero.
+const DefaultTxQueueLen = 4
+
+// ErrTxQueueFull is returned by [Inst.Submit]
+// if all transmit buffers are in use.
+var ErrTxQueueFull = errors.New("lan865x: tx: queue full")
+
+// TxClass is a transmit class. Frames of different classes are
+// queued separately, and scheduled according to [TxSched].
+// Higher classes have higher priority.
+type TxClass uint8
+
+const (
+	TxClassBulk TxClass = iota
+	TxClassDefault
+	TxClassPriority
+	TxClassControl
+
+	NumTxClasses = iota
+)
+
+// DefaultPCPClass maps 802.1p priority code points to transmit classes,
+// following the recommendation of IEEE 802.1Q for four traffic classes.
+// Untagged frames are treated like frames of PCP 0.
+var DefaultPCPClass = [8]TxClass{
+	TxClassDefault,  // 0 best effort
+	TxClassBulk,     // 1 background
+	TxClassBulk,     // 2 excellent effort
+	TxClassDefault,  // 3 critical applications
+	TxClassPriority, // 4 video
+	TxClassPriority, // 5 voice
+	TxClassControl,  // 6 internetwork control
+	TxClassControl,  // 7 network control
+}
+
+// DefaultTxWeights are the weights used by WeightedRoundRobin
+// if TxSched.Weights is all zero.
+var DefaultTxWeights = [NumTxClasses]int{1, 2, 4, 8}
+
+// TxSchedPolicy selects how the next frame to be transmitted
+// is chosen among the transmit classes.
+type TxSchedPolicy uint8
+
+const (
+	// StrictPriority transmits frames of a class only
+	// if no frames of higher classes are queued.
+	StrictPriority TxSchedPolicy = iota
+
+	// WeightedRoundRobin transmits, per round, up to as many frames
+	// of each class as defined by its weight, starting with the highest
+	// class. Lower classes are delayed, but not starved.
+	WeightedRoundRobin
+)
+
+// TxSched configures the transmit scheduler.
+type TxSched struct {
+	Policy TxSchedPolicy
+
+	// Weights contains the number of frames per round a class may
+	// transmit using WeightedRoundRobin; zero weights are treated as one.
+	// If all weights are zero, DefaultTxWeights is used.
+	Weights [NumTxClasses]int
+
+	// PCPClass, if not nil, replaces DefaultPCPClass to determine
+	// the class of frames polled from the upper layer.
+	PCPClass *[8]TxClass
+
+	// Reserve is the number of transmit buffers that are kept
+	// free for Inst.Submit while polling the upper layer.
+	Reserve int
+}
+
+// TxClassPoller may be implemented by an [t1s.UpperProto] that wants
+// to choose the transmit class of frames itself. If implemented,
+// the driver calls PollForEthClass instead of PollForEth.
+type TxClassPoller interface {
+	PollForEthClass(buf []byte) (n int, class TxClass, err error)
+}
+
+// txQueue holds frames waiting for transmission in one FIFO per class.
+// One frame at a time is passed to the oa-tc6 library, which keeps
+// a reference to the buffer until transmission has finished.
+// Since frames may be submitted by other goroutines, the
+// free list and the FIFOs are guarded by mu.
+type txQueue struct {
+	bufs [][]byte
+	busy int // index of the buffer passed to the library, or -1
+
+	sched   TxSched
+	weights [NumTxClasses]int
+	credit  [NumTxClasses]int
+
+	mu      sync.Mutex
+	free    []int
+	pending [NumTxClasses][]txFrame
+}
+
+type txFrame struct {
+	buf int
+	n   int
+}
+
+func (q *txQueue) init(n int, sched *TxSched) {
+	if n <= 0 {
+		n = DefaultTxQueueLen
+	}
+	mem := make([]byte, n*MTU)
+	q.bufs = make([][]byte, n)
+	q.free = make([]int, 0, n)
+	for i := range q.bufs {
+		q.bufs[i] = mem[i*MTU : (i+1)*MTU : (i+1)*MTU]
+		q.free = append(q.free, i)
+	}
+	for c := range q.pending {
+		q.pending[c] = make([]txFrame, 0, n)
+	}
+	q.busy = -1
+
+	q.sched = *sched
+	if q.sched.PCPClass == nil {
+		q.sched.PCPClass = &DefaultPCPClass
+	}
+	q.weights = q.sched.Weights
+	if q.weights == [NumTxClasses]int{} {
+		q.weights = DefaultTxWeights
+	}
+	for c, w := range q.weights {
+		q.weights[c] = max(w, 1)
+	}
+	q.credit = q.weights
+}
+
+// get removes a buffer from the free list, returning -1 if
+// no more than reserve buffers are available.
+func (q *txQueue) get(reserve int) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.free) <= reserve {
+		return -1
+	}
+	i := q.free[len(q.free)-1]
+	q.free = q.free[:len(q.free)-1]
+	return i
+}
+
+// push queues the first n bytes of buffer i in the FIFO of class c.
+func (q *txQueue) push(i, n int, c TxClass) {
+	q.mu.Lock()
+	q.pending[c] = append(q.pending[c], txFrame{buf: i, n: n})
+	q.mu.Unlock()
+}
+
+func (q *txQueue) release(i int) {
+	q.mu.Lock()
+	q.free = append(q.free, i)
+	q.mu.Unlock()
+}
+
+// pop removes the frame to be transmitted next from the queue.
+func (q *txQueue) pop() (f txFrame, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.sched.Policy == StrictPriority {
+		for c := NumTxClasses - 1; c >= 0; c-- {
+			if len(q.pending[c]) != 0 {
+				return q.take(c), true
+			}
+		}
+		return f, false
+	}
+	for round := 0; round < 2; round++ {
+		for c := NumTxClasses - 1; c >= 0; c-- {
+			if len(q.pending[c]) != 0 && q.credit[c] > 0 {
+				q.credit[c]--
+				return q.take(c), true
+			}
+		}
+		// All classes with queued frames have used up their
+		// weight; start a new round.
+		q.credit = q.weights
+	}
+	return f, false
+}
+
+func (q *txQueue) take(c int) txFrame {
+	p := q.pending[c]
+	f := p[0]
+	copy(p, p[1:])
+	q.pending[c] = p[:len(p)-1]
+	return f
+}
+
+// pcpClass returns the class of a frame according
+// to the PCP of its VLAN tag.
+func (q *txQueue) pcpClass(frame []byte) TxClass {
+	var pcp uint8
+	if f := t1s.Frame(frame); f.Valid() {
+		if tag, ok := f.VLANTag(); ok {
+			pcp = tag.PCP & 7
+		}
+	}
+	return q.sched.PCPClass[pcp]
+}
+
+// Submit queues a frame for transmission in class c, copying it
+// into a transmit buffer. It may be called from any goroutine;
+// the frame will be passed to the MAC-PHY by [Inst.Service].
+// If no buffer is available, ErrTxQueueFull is returned.
+func (inst *Inst) Submit(c TxClass, frame []byte) error {
+	if c >= NumTxClasses {
+		c = NumTxClasses - 1
+	}
+	if len(frame) == 0 || len(frame) > MTU {
+		inst.counters.txErrors.Add(1)
+		return ErrSendFailure
+	}
+	q := &inst.txq
+	i := q.get(0)
+	if i == -1 {
+		inst.counters.txOverflows.Add(1)
+		return ErrTxQueueFull
+	}
+	n := copy(q.bufs[i], frame)
+	q.push(i, n, c)
+	return nil
+}
+
+// pollTx polls the upper layer for frames while transmit buffers
+// are available. It reports whether any frame has been queued.
+func (inst *Inst) pollTx() (polled bool) {
+	q := &inst.txq
+	cp, hasClass := inst.UpperProto.(TxClassPoller)
+	for {
+		i := q.get(q.sched.Reserve)
+		if i == -1 {
+			return polled
+		}
+		var n int
+		var c TxClass
+		var err error
+		if hasClass {
+			n, c, err = cp.PollForEthClass(q.bufs[i])
+		} else {
+			n, err = inst.UpperProto.PollForEth(q.bufs[i])
+		}
+		if err != nil || n == 0 {
+			q.release(i)
+			return polled
+		}
+		if hasClass {
+			c = min(c, NumTxClasses-1)
+		} else {
+			c = q.pcpClass(q.bufs[i][:n])
+		}
+		q.push(i, n, c)
+		polled = true
+	}
+}
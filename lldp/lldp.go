@@ -0,0 +1,334 @@
+// Package lldp implements an IEEE 802.1AB Link Layer Discovery Protocol
+// agent as a [t1s.UpperProto] adapter. The agent advertises the node's
+// identity and PLCA settings, and maintains a table of the neighbors
+// whose advertisements it receives. Since the nodes of a 10BASE-T1S
+// multidrop segment share the medium, the table contains all
+// nodes of the segment that run an agent.
+package lldp
+
+import (
+	"bytes"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/knieriem/t1s"
+)
+
+// Addr is the nearest bridge group address LLDPDUs are sent to.
+// It must pass the address filter of the driver, for instance
+// by adding it to [t1s.MACConf.Multicast].
+var Addr = [t1s.AddrLen]byte{0x01, 0x80, 0xC2, 0x00, 0x00, 0x0E}
+
+// DefaultOUI identifies the organizationally specific TLV carrying
+// PLCA settings, if Agent.OUI is zero. It is a value from the
+// locally assigned CID space, as there is no standardized TLV.
+var DefaultOUI = [3]byte{0x0A, 0x74, 0x31}
+
+const (
+	DefaultInterval     = 30 * time.Second
+	DefaultMaxNeighbors = 32
+
+	// txHold is the multiplier applied to the transmit
+	// interval to get the TTL of advertisements.
+	txHold = 4
+)
+
+// Neighbor describes a node whose advertisement has been received.
+type Neighbor struct {
+	Addr [t1s.AddrLen]byte // source address of the LLDPDU
+
+	ChassisSubtype uint8
+	ChassisID      []byte
+	PortSubtype    uint8
+	PortID         []byte
+
+	TTL               uint16 // seconds
+	SystemName        string
+	SystemDescription string
+	MgmtAddr          netip.Addr
+
+	// PLCAAdvertised reports whether the neighbor sent a PLCA TLV.
+	// PLCA is nil, if it did not, or if the neighbor uses CSMA/CD.
+	PLCAAdvertised bool
+	PLCA           *t1s.PLCAConf
+
+	// LastSeen is the time the last advertisement has been
+	// received, in milliseconds, as returned by the TicksProvider.
+	LastSeen uint32
+}
+
+// Stats contains counters of an Agent.
+type Stats struct {
+	TxFrames   uint64
+	RxFrames   uint64
+	RxDiscards uint64 // malformed LLDPDUs
+	Inserts    uint64 // neighbors added to the table
+	Deletes    uint64 // neighbors removed after a shutdown LLDPDU
+	Ageouts    uint64 // neighbors removed after their TTL expired
+	Drops      uint64 // neighbors not added because the table was full
+}
+
+// Agent is an LLDP agent. Frames of other protocols are
+// passed to and polled from the Next upper layer.
+//
+// Like [t1s.UpperProto] methods, the settings must not be changed
+// while the agent is in use, except from within the goroutine
+// calling its UpperProto methods, followed by a call to Trigger.
+type Agent struct {
+	MAC  *t1s.MACConf  // Addr is used as chassis ID and port ID
+	PLCA *t1s.PLCAConf // nil means CSMA/CD
+
+	SystemName        string
+	SystemDescription string
+	MgmtAddr          netip.Addr
+
+	// OUI identifies the PLCA TLV; if zero, DefaultOUI is used.
+	OUI [3]byte
+
+	// Interval is the transmit interval; if zero, DefaultInterval
+	// is used. Advertisements are valid for four intervals.
+	Interval time.Duration
+
+	// MaxNeighbors limits the size of the neighbor table;
+	// if zero, DefaultMaxNeighbors is used.
+	MaxNeighbors int
+
+	Ticks t1s.TicksProvider
+	Next  t1s.UpperProto
+
+	mu        sync.Mutex
+	neighbors []Neighbor
+	stats     Stats
+	started   bool
+	due       uint32 // time of the next transmission
+	trigger   bool
+	shutdown  int // 1: shutdown LLDPDU pending, 2: sent
+}
+
+// SendEthUp processes LLDPDUs, and passes other frames to a.Next.
+func (a *Agent) SendEthUp(pkt []byte) error {
+	f := t1s.Frame(pkt)
+	if !f.Valid() || f.EtherType() != t1s.EtherTypeLLDP {
+		if a.Next == nil {
+			return nil
+		}
+		return a.Next.SendEthUp(pkt)
+	}
+	var nb Neighbor
+	err := parse(f.Payload(), a.oui(), &nb)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.stats.RxDiscards++
+		return nil
+	}
+	a.stats.RxFrames++
+	nb.Addr = f.Src()
+	nb.LastSeen = a.Ticks.Milliseconds()
+	a.update(&nb)
+	return nil
+}
+
+// update inserts or replaces the table entry of nb,
+// or removes it, if nb has a TTL of zero.
+func (a *Agent) update(nb *Neighbor) {
+	i := a.lookup(nb)
+	if nb.TTL == 0 {
+		if i != -1 {
+			a.remove(i)
+			a.stats.Deletes++
+		}
+		return
+	}
+	// The IDs refer to the frame buffer, which gets reused.
+	nb.ChassisID = bytes.Clone(nb.ChassisID)
+	nb.PortID = bytes.Clone(nb.PortID)
+	if i != -1 {
+		a.neighbors[i] = *nb
+		return
+	}
+	limit := a.MaxNeighbors
+	if limit == 0 {
+		limit = DefaultMaxNeighbors
+	}
+	if len(a.neighbors) >= limit {
+		a.stats.Drops++
+		return
+	}
+	a.neighbors = append(a.neighbors, *nb)
+	a.stats.Inserts++
+}
+
+// lookup returns the index of the entry with the same
+// chassis ID and port ID as nb, or -1.
+func (a *Agent) lookup(nb *Neighbor) int {
+	for i := range a.neighbors {
+		e := &a.neighbors[i]
+		if e.ChassisSubtype == nb.ChassisSubtype && bytes.Equal(e.ChassisID, nb.ChassisID) &&
+			e.PortSubtype == nb.PortSubtype && bytes.Equal(e.PortID, nb.PortID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *Agent) remove(i int) {
+	a.neighbors = append(a.neighbors[:i], a.neighbors[i+1:]...)
+}
+
+// PollForEth ages out neighbors, and returns an LLDPDU if one is due;
+// otherwise a.Next is polled.
+func (a *Agent) PollForEth(buf []byte) (int, error) {
+	now := a.Ticks.Milliseconds()
+	a.mu.Lock()
+	a.age(now)
+	send, final := a.txDue(now)
+	a.mu.Unlock()
+	if send {
+		n, err := a.build(buf, final)
+		if err != nil {
+			return 0, err
+		}
+		a.mu.Lock()
+		a.stats.TxFrames++
+		a.mu.Unlock()
+		return n, nil
+	}
+	if a.Next == nil {
+		return 0, nil
+	}
+	return a.Next.PollForEth(buf)
+}
+
+func (a *Agent) age(now uint32) {
+	for i := 0; i < len(a.neighbors); {
+		nb := &a.neighbors[i]
+		if now-nb.LastSeen >= uint32(nb.TTL)*1000 {
+			a.remove(i)
+			a.stats.Ageouts++
+			continue
+		}
+		i++
+	}
+}
+
+// txDue reports whether an LLDPDU shall be sent, and whether it is
+// a shutdown LLDPDU, and schedules the next transmission.
+func (a *Agent) txDue(now uint32) (send, final bool) {
+	switch a.shutdown {
+	case 1:
+		a.shutdown = 2
+		return true, true
+	case 2:
+		return false, false
+	}
+	if a.started && !a.trigger && int32(now-a.due) < 0 {
+		return false, false
+	}
+	a.started = true
+	a.trigger = false
+	a.due = now + uint32(a.interval()/time.Millisecond)
+	return true, false
+}
+
+func (a *Agent) interval() time.Duration {
+	if a.Interval == 0 {
+		return DefaultInterval
+	}
+	return a.Interval
+}
+
+func (a *Agent) oui() [3]byte {
+	if a.OUI == [3]byte{} {
+		return DefaultOUI
+	}
+	return a.OUI
+}
+
+// build writes an LLDPDU to buf; if final is set,
+// a shutdown LLDPDU with a TTL of zero.
+func (a *Agent) build(buf []byte, final bool) (int, error) {
+	h := t1s.Header{Dst: Addr, Src: a.MAC.Addr, EtherType: t1s.EtherTypeLLDP}
+	n, err := h.Put(buf)
+	if err != nil {
+		return 0, err
+	}
+	e := encoder{buf: buf, n: n}
+	if b := e.tlv(tlvChassisID, 1+t1s.AddrLen); b != nil {
+		b[0] = ChassisIDMAC
+		copy(b[1:], a.MAC.Addr[:])
+	}
+	if b := e.tlv(tlvPortID, 1+t1s.AddrLen); b != nil {
+		b[0] = PortIDMAC
+		copy(b[1:], a.MAC.Addr[:])
+	}
+	ttl := 0
+	if !final {
+		ttl = min(int((txHold*a.interval()+time.Second-1)/time.Second), 0xFFFF)
+	}
+	if b := e.tlv(tlvTTL, 2); b != nil {
+		putBE16(b, uint16(ttl))
+	}
+	if ttl != 0 {
+		e.str(tlvSysName, a.SystemName)
+		e.str(tlvSysDesc, a.SystemDescription)
+		e.mgmtAddr(a.MgmtAddr)
+		e.plca(a.oui(), a.PLCA)
+	}
+	e.tlv(tlvEnd, 0)
+	if e.err != nil {
+		return 0, e.err
+	}
+	return t1s.Pad(buf, e.n)
+}
+
+// Trigger makes the agent send an advertisement at the next poll,
+// for instance after the PLCA settings have been changed.
+// It may be called from any goroutine.
+func (a *Agent) Trigger() {
+	a.mu.Lock()
+	a.trigger = true
+	a.mu.Unlock()
+}
+
+// Shutdown makes the agent send a shutdown LLDPDU at the next poll,
+// which makes neighbors remove the node from their tables.
+// Afterwards, the agent stops transmitting.
+// It may be called from any goroutine.
+func (a *Agent) Shutdown() {
+	a.mu.Lock()
+	if a.shutdown == 0 {
+		a.shutdown = 1
+	}
+	a.mu.Unlock()
+}
+
+// Neighbors returns a copy of the neighbor table, sorted by
+// PLCA node ID, and by address. Neighbors without PLCA settings
+// are listed last. It may be called from any goroutine.
+func (a *Agent) Neighbors() []Neighbor {
+	a.mu.Lock()
+	list := append([]Neighbor(nil), a.neighbors...)
+	a.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		pi, pj := list[i].PLCA, list[j].PLCA
+		switch {
+		case pi != nil && pj != nil && pi.NodeID != pj.NodeID:
+			return pi.NodeID < pj.NodeID
+		case (pi == nil) != (pj == nil):
+			return pi != nil
+		}
+		return bytes.Compare(list[i].Addr[:], list[j].Addr[:]) < 0
+	})
+	return list
+}
+
+// Stats returns the counters of a.
+// It may be called from any goroutine.
+func (a *Agent) Stats() Stats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
@@ -0,0 +1,204 @@
+package lldp
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/knieriem/t1s"
+)
+
+// TLV types of IEEE 802.1AB.
+const (
+	tlvEnd         = 0
+	tlvChassisID   = 1
+	tlvPortID      = 2
+	tlvTTL         = 3
+	tlvPortDesc    = 4
+	tlvSysName     = 5
+	tlvSysDesc     = 6
+	tlvMgmtAddr    = 8
+	tlvOrgSpecific = 127
+
+	maxTLVLen = 511
+)
+
+// Subtypes of the chassis ID and port ID TLVs
+// used for MAC addresses.
+const (
+	ChassisIDMAC = 4
+	PortIDMAC    = 3
+)
+
+// Address family numbers of management addresses.
+const (
+	afIPv4 = 1
+	afIPv6 = 2
+)
+
+// plcaSubtype is the subtype of the organizationally specific TLV
+// carrying PLCA settings. Its information string consists of
+// a flags byte, the node ID, the node count, the burst count,
+// and the burst timer.
+const (
+	plcaSubtype = 1
+	plcaInfoLen = 5
+
+	plcaEnabled = 1 << 0
+)
+
+var (
+	ErrMalformed = errors.New("lldp: malformed LLDPDU")
+	ErrShortBuf  = errors.New("lldp: buffer too small for LLDPDU")
+)
+
+// encoder appends TLVs to a buffer.
+type encoder struct {
+	buf []byte
+	n   int
+	err error
+}
+
+// tlv reserves space for a TLV of type typ with an information string
+// of n bytes, and returns the slice the string must be written to.
+func (e *encoder) tlv(typ, n int) []byte {
+	if e.err != nil {
+		return nil
+	}
+	if n > maxTLVLen || len(e.buf)-e.n < 2+n {
+		e.err = ErrShortBuf
+		return nil
+	}
+	putBE16(e.buf[e.n:], uint16(typ)<<9|uint16(n))
+	b := e.buf[e.n+2 : e.n+2+n]
+	e.n += 2 + n
+	return b
+}
+
+func (e *encoder) str(typ int, s string) {
+	if s == "" {
+		return
+	}
+	if len(s) > maxTLVLen {
+		s = s[:maxTLVLen]
+	}
+	if b := e.tlv(typ, len(s)); b != nil {
+		copy(b, s)
+	}
+}
+
+func (e *encoder) mgmtAddr(a netip.Addr) {
+	if !a.IsValid() {
+		return
+	}
+	af := afIPv6
+	if a.Is4() {
+		af = afIPv4
+	}
+	ab := a.AsSlice()
+
+	// address string length, subtype, address, interface numbering
+	// subtype (unknown), interface number, OID string length
+	b := e.tlv(tlvMgmtAddr, 1+1+len(ab)+1+4+1)
+	if b == nil {
+		return
+	}
+	b[0] = byte(1 + len(ab))
+	b[1] = byte(af)
+	i := 2 + copy(b[2:], ab)
+	b[i] = 1
+	clear(b[i+1:])
+}
+
+func (e *encoder) plca(oui [3]byte, c *t1s.PLCAConf) {
+	b := e.tlv(tlvOrgSpecific, 4+plcaInfoLen)
+	if b == nil {
+		return
+	}
+	copy(b, oui[:])
+	b[3] = plcaSubtype
+	clear(b[4:])
+	if c != nil {
+		b[4] = plcaEnabled
+		b[5] = c.NodeID
+		b[6] = c.NodeCount
+		b[7] = c.BurstCount
+		b[8] = c.BurstTimer
+	}
+}
+
+// parse decodes an LLDPDU into nb. The byte slices of nb
+// refer to p. Unknown TLVs are ignored.
+func parse(p []byte, oui [3]byte, nb *Neighbor) error {
+	for i := 0; ; i++ {
+		if len(p) < 2 {
+			return ErrMalformed
+		}
+		h := be16(p)
+		typ, n := int(h>>9), int(h&maxTLVLen)
+		if len(p) < 2+n {
+			return ErrMalformed
+		}
+		b := p[2 : 2+n]
+		p = p[2+n:]
+
+		// The LLDPDU must start with the chassis ID,
+		// port ID, and TTL TLVs, in this order.
+		if i < 3 && typ != i+1 {
+			return ErrMalformed
+		}
+		switch typ {
+		case tlvEnd:
+			return nil
+		case tlvChassisID, tlvPortID:
+			if n < 2 || n > 256 {
+				return ErrMalformed
+			}
+			if typ == tlvChassisID {
+				nb.ChassisSubtype, nb.ChassisID = b[0], b[1:]
+			} else {
+				nb.PortSubtype, nb.PortID = b[0], b[1:]
+			}
+		case tlvTTL:
+			if n < 2 {
+				return ErrMalformed
+			}
+			nb.TTL = be16(b)
+		case tlvSysName:
+			nb.SystemName = string(b)
+		case tlvSysDesc:
+			nb.SystemDescription = string(b)
+		case tlvMgmtAddr:
+			if n < 2 || int(b[0]) < 1 || int(b[0]) > n-1 || nb.MgmtAddr.IsValid() {
+				break
+			}
+			switch a := b[2 : 1+b[0]]; {
+			case b[1] == afIPv4 && len(a) == 4:
+				nb.MgmtAddr = netip.AddrFrom4([4]byte(a))
+			case b[1] == afIPv6 && len(a) == 16:
+				nb.MgmtAddr = netip.AddrFrom16([16]byte(a))
+			}
+		case tlvOrgSpecific:
+			if n < 4+plcaInfoLen || [3]byte(b[:3]) != oui || b[3] != plcaSubtype {
+				break
+			}
+			nb.PLCAAdvertised = true
+			if b[4]&plcaEnabled != 0 {
+				nb.PLCA = &t1s.PLCAConf{
+					NodeID:     b[5],
+					NodeCount:  b[6],
+					BurstCount: b[7],
+					BurstTimer: b[8],
+				}
+			}
+		}
+	}
+}
+
+func be16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func putBE16(b []byte, v uint16) {
+	b[0] = byte(v >> 8)
+	b[1] = byte(v)
+}
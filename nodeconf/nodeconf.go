@@ -0,0 +1,330 @@
+// Package nodeconf defines a declarative configuration file format for
+// T1S nodes. A configuration, stored as JSON, describes the MAC and PLCA
+// settings, the wiring of the MAC-PHY, IP settings, and log levels:
+//
+//	{
+//		"mac": {"addr": "02:00:00:00:00:01", "multicast": ["01:00:5e:00:00:fb"]},
+//		"plca": {"nodeId": 1, "nodeCount": 8, "burstTimer": 128},
+//		"hardware": {"spiDev": "/dev/spidev0.1", "spiClockHz": 5000000, "resetPin": "GPIO13", "intrPin": "GPIO26"},
+//		"ip": {"addr": "192.168.5.100/24"},
+//		"log": {"main": "info", "t1s": "error"}
+//	}
+//
+// If "plca" is missing or null, CSMA/CD is used. Hardware settings
+// that are not specified are left to the defaults of the program.
+//
+// Errors detected while loading a configuration refer to the
+// offending field using a path like "plca.nodeCount" or "mac.multicast[1]".
+package nodeconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/knieriem/t1s"
+)
+
+// Node is the configuration of a node.
+type Node struct {
+	MAC      MAC               `json:"mac"`
+	PLCA     *PLCA             `json:"plca,omitempty"`
+	Hardware Hardware          `json:"hardware"`
+	IP       IP                `json:"ip"`
+	Log      map[string]string `json:"log,omitempty"`
+}
+
+// MAC corresponds to [t1s.MACConf]; addresses are
+// specified in the form accepted by [net.ParseMAC].
+type MAC struct {
+	Addr             string   `json:"addr"`
+	Multicast        []string `json:"multicast,omitempty"`
+	AllMulticast     bool     `json:"allMulticast,omitempty"`
+	RejectBroadcast  bool     `json:"rejectBroadcast,omitempty"`
+	DiscardUnmatched bool     `json:"discardUnmatched,omitempty"`
+	CopyAllFrames    bool     `json:"copyAllFrames,omitempty"`
+	TxCutThrough     bool     `json:"txCutThrough,omitempty"`
+	RxCutThrough     bool     `json:"rxCutThrough,omitempty"`
+}
+
+// PLCA corresponds to [t1s.PLCAConf].
+type PLCA struct {
+	NodeID     uint8 `json:"nodeId"`
+	NodeCount  uint8 `json:"nodeCount"`
+	BurstCount uint8 `json:"burstCount,omitempty"`
+	BurstTimer uint8 `json:"burstTimer,omitempty"`
+}
+
+// Hardware describes how the MAC-PHY is connected.
+type Hardware struct {
+	SPIDev     string `json:"spiDev,omitempty"`
+	SPIClockHz int    `json:"spiClockHz,omitempty"`
+	SPIMode    int    `json:"spiMode,omitempty"`
+	ResetPin   string `json:"resetPin,omitempty"`
+	IntrPin    string `json:"intrPin,omitempty"`
+	WakePin    string `json:"wakePin,omitempty"`
+}
+
+// MaxSPIClockHz is the maximum SPI clock frequency supported by the LAN865x.
+const MaxSPIClockHz = 25000000
+
+// IP contains the IP settings. Addr may contain a prefix length.
+type IP struct {
+	Addr    string `json:"addr,omitempty"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// FieldError describes an invalid field of a configuration.
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// SyntaxError is a JSON syntax error at a position of a configuration.
+type SyntaxError struct {
+	Line, Col int
+	Err       error
+}
+
+func (e *SyntaxError) Error() string {
+	return strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Col) + ": " + e.Err.Error()
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	ErrRequired     = errors.New("missing value")
+	ErrNotUnicast   = errors.New("not a unicast address")
+	ErrNotMulticast = errors.New("not a multicast address")
+	ErrRange        = errors.New("value out of range")
+)
+
+// LoadFile reads a configuration from the named file.
+func LoadFile(name string) (*Node, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+// Load reads a configuration, and validates it. Unknown fields are
+// rejected. If the configuration is invalid, the returned error
+// joins a [FieldError] for each invalid field.
+func Load(r io.Reader) (*Node, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	n := new(Node)
+	err = d.Decode(n)
+	if err != nil {
+		return nil, decodeError(data, err)
+	}
+	err = n.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func decodeError(data []byte, err error) error {
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &se):
+		line, col := position(data, se.Offset)
+		return &SyntaxError{Line: line, Col: col, Err: err}
+	case errors.As(err, &te):
+		if te.Field != "" {
+			return &FieldError{Path: te.Field, Err: fmt.Errorf("cannot use %s as %s", te.Value, te.Type)}
+		}
+	}
+	return err
+}
+
+// position returns the line and column of the byte that caused
+// a syntax error; offset, as reported by encoding/json, includes it.
+func position(data []byte, offset int64) (line, col int) {
+	data = data[:min(max(int(offset)-1, 0), len(data))]
+	line = 1 + bytes.Count(data, []byte{'\n'})
+	col = len(data) - bytes.LastIndexByte(data, '\n')
+	return line, col
+}
+
+// Validate checks all fields of n.
+func (n *Node) Validate() error {
+	var errs []error
+	add := func(path string, err error) {
+		errs = append(errs, &FieldError{Path: path, Err: err})
+	}
+
+	m := &n.MAC
+	if m.Addr == "" {
+		add("mac.addr", ErrRequired)
+	} else if a, err := parseMAC(m.Addr); err != nil {
+		add("mac.addr", err)
+	} else if a[0]&1 != 0 {
+		add("mac.addr", ErrNotUnicast)
+	}
+	for i, s := range m.Multicast {
+		path := "mac.multicast[" + strconv.Itoa(i) + "]"
+		if a, err := parseMAC(s); err != nil {
+			add(path, err)
+		} else if a[0]&1 == 0 {
+			add(path, ErrNotMulticast)
+		}
+	}
+
+	if c := n.PLCAConf(); c != nil {
+		var pe *t1s.PLCAConfError
+		if err := c.Validate(); errors.As(err, &pe) {
+			add("plca."+plcaFields[pe.Field], pe.Err)
+		} else if err != nil {
+			add("plca", err)
+		}
+	}
+
+	hw := &n.Hardware
+	if hw.SPIClockHz < 0 || hw.SPIClockHz > MaxSPIClockHz {
+		add("hardware.spiClockHz", ErrRange)
+	}
+	if hw.SPIMode < 0 || hw.SPIMode > 3 {
+		add("hardware.spiMode", ErrRange)
+	}
+
+	if s := n.IP.Addr; s != "" {
+		if _, err := parseIP(s); err != nil {
+			add("ip.addr", err)
+		}
+	}
+	if s := n.IP.Gateway; s != "" {
+		if _, err := netip.ParseAddr(s); err != nil {
+			add("ip.gateway", err)
+		}
+	}
+
+	names := make([]string, 0, len(n.Log))
+	for name := range n.Log {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(n.Log[name])); err != nil {
+			add("log."+name, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var plcaFields = map[string]string{
+	"NodeID":     "nodeId",
+	"NodeCount":  "nodeCount",
+	"BurstCount": "burstCount",
+	"BurstTimer": "burstTimer",
+}
+
+func parseMAC(s string) (a [6]byte, err error) {
+	hw, err := net.ParseMAC(s)
+	if err != nil {
+		return a, err
+	}
+	if len(hw) != 6 {
+		return a, errors.New("invalid MAC address " + s)
+	}
+	return [6]byte(hw), nil
+}
+
+func parseIP(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err == nil {
+		return p, nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return p, err
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// MACConf returns the MAC settings of a validated configuration.
+func (n *Node) MACConf() *t1s.MACConf {
+	m := &n.MAC
+	c := &t1s.MACConf{
+		AllMulticast:     m.AllMulticast,
+		RejectBroadcast:  m.RejectBroadcast,
+		DiscardUnmatched: m.DiscardUnmatched,
+		CopyAllFrames:    m.CopyAllFrames,
+		TxCutThrough:     m.TxCutThrough,
+		RxCutThrough:     m.RxCutThrough,
+	}
+	c.Addr, _ = parseMAC(m.Addr)
+	for _, s := range m.Multicast {
+		a, _ := parseMAC(s)
+		c.Multicast = append(c.Multicast, a)
+	}
+	return c
+}
+
+// PLCAConf returns the PLCA settings, or nil, if CSMA/CD shall be used.
+func (n *Node) PLCAConf() *t1s.PLCAConf {
+	p := n.PLCA
+	if p == nil {
+		return nil
+	}
+	return &t1s.PLCAConf{
+		NodeID:     p.NodeID,
+		NodeCount:  p.NodeCount,
+		BurstCount: p.BurstCount,
+		BurstTimer: p.BurstTimer,
+	}
+}
+
+// IPPrefix returns the IP address including the prefix length; if
+// the configuration does not contain a prefix length, the prefix
+// covers the full address. If no address is configured, the zero
+// Prefix is returned.
+func (n *Node) IPPrefix() netip.Prefix {
+	p, _ := parseIP(n.IP.Addr)
+	return p
+}
+
+// LogLevel returns the log level configured for name,
+// or def, if no level is configured.
+func (n *Node) LogLevel(name string, def slog.Level) slog.Level {
+	s, ok := n.Log[name]
+	if !ok {
+		return def
+	}
+	var l slog.Level
+	if l.UnmarshalText([]byte(s)) != nil {
+		return def
+	}
+	return l
+}
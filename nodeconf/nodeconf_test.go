@@ -0,0 +1,109 @@
+package nodeconf_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/knieriem/t1s/nodeconf"
+)
+
+// paths returns the paths of the FieldErrors joined in err.
+func paths(err error) []string {
+	var list []string
+	errs := []error{err}
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = j.Unwrap()
+	}
+	for _, err := range errs {
+		var fe *nodeconf.FieldError
+		if errors.As(err, &fe) {
+			list = append(list, fe.Path)
+		}
+	}
+	return list
+}
+
+func TestLoad(t *testing.T) {
+	const conf = `{
+	"mac": {"addr": "02:00:00:00:00:01", "multicast": ["01:00:5e:00:00:fb"]},
+	"plca": {"nodeId": 1, "nodeCount": 8, "burstCount": 1, "burstTimer": 128},
+	"hardware": {"spiDev": "/dev/spidev0.1", "spiClockHz": 5000000},
+	"ip": {"addr": "192.168.5.100/24", "gateway": "192.168.5.1"},
+	"log": {"main": "info", "t1s": "error"}
+}`
+	n, err := nodeconf.Load(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := n.MACConf(); c.Addr != [6]byte{0x02, 0, 0, 0, 0, 1} || len(c.Multicast) != 1 {
+		t.Errorf("MAC: %+v", c)
+	}
+	if c := n.PLCAConf(); c == nil || c.NodeID != 1 || c.NodeCount != 8 || c.BurstTimer != 128 {
+		t.Errorf("PLCA: %+v", c)
+	}
+	if p := n.IPPrefix(); p.String() != "192.168.5.100/24" {
+		t.Errorf("IP: %v", p)
+	}
+}
+
+func TestLoadFieldErrors(t *testing.T) {
+	for _, tc := range []struct {
+		conf string
+		want []string
+	}{
+		{`{"mac": {}}`, []string{"mac.addr"}},
+		{`{"mac": {"addr": "01:00:5e:00:00:01"}}`, []string{"mac.addr"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01", "multicast": ["01:00:5e:00:00:fb", "02:00:00:00:00:02", "x"]}}`,
+			[]string{"mac.multicast[1]", "mac.multicast[2]"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "plca": {"nodeId": 1, "nodeCount": 0}}`, []string{"plca.nodeCount"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "plca": {"nodeId": 8, "nodeCount": 8}}`, []string{"plca.nodeId"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "plca": {"nodeId": 1, "nodeCount": 8, "burstCount": 1}}`, []string{"plca.burstTimer"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "hardware": {"spiClockHz": 30000000, "spiMode": 4}}`,
+			[]string{"hardware.spiClockHz", "hardware.spiMode"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "ip": {"addr": "192.168.5", "gateway": "x"}}`, []string{"ip.addr", "ip.gateway"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "log": {"t1s": "loud", "main": "verbose"}}`, []string{"log.main", "log.t1s"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "plca": {"nodeId": 300}}`, []string{"plca.nodeId"}},
+		{`{"mac": {"addr": "02:00:00:00:00:01"}, "hardware": {"spiClockHz": "fast"}}`, []string{"hardware.spiClockHz"}},
+	} {
+		_, err := nodeconf.Load(strings.NewReader(tc.conf))
+		if got := paths(err); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: error %v; paths %q, want %q", tc.conf, err, got, tc.want)
+		}
+	}
+}
+
+func TestLoadSyntaxError(t *testing.T) {
+	for _, tc := range []struct {
+		conf      string
+		line, col int
+	}{
+		{`{"mac": {"addr": "02:00:00:00:00:01"},}`, 1, 39},
+		{"{\n\t\"mac\": {\n\t\t\"addr\": \"02:00:00:00:00:01\",\n\t}\n}", 4, 2},
+		{"{\n\t\"plca\": {\"nodeId\": 1 \"nodeCount\": 8}\n}", 2, 23},
+	} {
+		_, err := nodeconf.Load(strings.NewReader(tc.conf))
+		var se *nodeconf.SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: error %v, want a SyntaxError", tc.conf, err)
+			continue
+		}
+		if se.Line != tc.line || se.Col != tc.col {
+			t.Errorf("%q: error at %d:%d, want %d:%d", tc.conf, se.Line, se.Col, tc.line, tc.col)
+		}
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	for _, conf := range []string{
+		`{"mac": {"addr": "02:00:00:00:00:01"}, "phy": {}}`,
+		`{"mac": {"addr": "02:00:00:00:00:01", "adr": "02:00:00:00:00:02"}}`,
+		`{"mac": {"addr": "02:00:00:00:00:01"}, "plca": {"nodeId": 1, "nodeCnt": 8}}`,
+	} {
+		_, err := nodeconf.Load(strings.NewReader(conf))
+		if err == nil || !strings.Contains(err.Error(), "unknown field") {
+			t.Errorf("%s: error %v, want an unknown field error", conf, err)
+		}
+	}
+}
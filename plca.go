@@ -0,0 +1,212 @@
+package t1s
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PLCACoordinatorID is the node ID of the PLCA coordinator,
+	// which emits the beacons.
+	PLCACoordinatorID = 0
+
+	// PLCAUnconfiguredID is the node ID of a PHY
+	// that has not been assigned an ID yet.
+	PLCAUnconfiguredID = 255
+
+	// PLCAMinBurstTimer is the minimum burst timer value, in bit times,
+	// required for a node to be able to send further frames in a burst;
+	// it equals the inter-packet gap.
+	PLCAMinBurstTimer = 96
+)
+
+var (
+	ErrPLCANodeID        = errors.New("node ID 255 is reserved for unconfigured nodes")
+	ErrPLCANodeCount     = errors.New("node count must be in 1..255")
+	ErrPLCANodeIDRange   = errors.New("node ID not below node count")
+	ErrPLCABurstTimer    = errors.New("burst timer too short for bursts")
+	ErrNoCoordinator     = errors.New("no PLCA coordinator")
+	ErrMultiCoordinators = errors.New("more than one PLCA coordinator")
+	ErrDuplicateNodeID   = errors.New("duplicate PLCA node ID")
+	ErrNodeIDNotCovered  = errors.New("PLCA node ID not covered by the coordinator's node count")
+	ErrNodeCountMismatch = errors.New("PLCA node count differs from the coordinator's")
+	ErrPLCADisabled      = errors.New("PLCA disabled on a PLCA segment")
+)
+
+// PLCAConfError reports an invalid field of a PLCAConf.
+type PLCAConfError struct {
+	Field string // name of the field, like "NodeCount"
+	Err   error
+}
+
+func (e *PLCAConfError) Error() string {
+	return "plca: " + e.Field + ": " + e.Err.Error()
+}
+
+func (e *PLCAConfError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks whether the settings of c can be applied to a PHY.
+// NodeID must be below NodeCount, so that the node gets a transmit
+// opportunity, even though the node count is evaluated by the
+// coordinator only. If BurstCount is not zero, BurstTimer must
+// be at least PLCAMinBurstTimer.
+func (c *PLCAConf) Validate() error {
+	switch {
+	case c.NodeID == PLCAUnconfiguredID:
+		return &PLCAConfError{Field: "NodeID", Err: ErrPLCANodeID}
+	case c.NodeCount == 0:
+		return &PLCAConfError{Field: "NodeCount", Err: ErrPLCANodeCount}
+	case c.NodeID >= c.NodeCount:
+		return &PLCAConfError{Field: "NodeID", Err: ErrPLCANodeIDRange}
+	case c.BurstCount != 0 && c.BurstTimer < PLCAMinBurstTimer:
+		return &PLCAConfError{Field: "BurstTimer", Err: ErrPLCABurstTimer}
+	}
+	return nil
+}
+
+// SegmentNode describes a node attached to a segment.
+type SegmentNode struct {
+	Name string
+
+	// PLCA is the node's configuration; nil means CSMA/CD.
+	PLCA *PLCAConf
+}
+
+// SegmentConflict is a problem detected by CheckSegment.
+// It lists the names of the nodes involved.
+type SegmentConflict struct {
+	Nodes  []string
+	NodeID int // the node ID concerned, or -1
+	Err    error
+}
+
+func (c *SegmentConflict) Error() string {
+	s := strings.Join(c.Nodes, ", ")
+	if s != "" {
+		s += ": "
+	}
+	s += c.Err.Error()
+	if c.NodeID >= 0 {
+		s += ": " + strconv.Itoa(c.NodeID)
+	}
+	return s
+}
+
+func (c *SegmentConflict) Unwrap() error {
+	return c.Err
+}
+
+// CheckSegment checks the PLCA configurations of the nodes of a segment.
+// It reports invalid configurations, as determined by PLCAConf.Validate,
+// and conflicts between nodes: node IDs used more than once, the absence
+// of a coordinator, or more than one coordinator, node IDs
+// that the coordinator's node count does not cover, and node counts
+// differing from the coordinator's. If at least one node uses PLCA,
+// nodes using CSMA/CD are reported, too; otherwise, no conflicts
+// are reported.
+func CheckSegment(nodes []SegmentNode) []*SegmentConflict {
+	var list []*SegmentConflict
+	add := func(err error, id int, names ...string) {
+		list = append(list, &SegmentConflict{Nodes: names, NodeID: id, Err: err})
+	}
+
+	byID := make(map[uint8][]string)
+	nPLCA := 0
+	for i := range nodes {
+		n := &nodes[i]
+		if n.PLCA == nil {
+			continue
+		}
+		nPLCA++
+		if err := n.PLCA.Validate(); err != nil {
+			add(err, -1, n.Name)
+		}
+		byID[n.PLCA.NodeID] = append(byID[n.PLCA.NodeID], n.Name)
+	}
+	if nPLCA == 0 {
+		return nil
+	}
+	var csma []string
+	for i := range nodes {
+		if nodes[i].PLCA == nil {
+			csma = append(csma, nodes[i].Name)
+		}
+	}
+	if len(csma) != 0 {
+		add(ErrPLCADisabled, -1, csma...)
+	}
+
+	coord := byID[PLCACoordinatorID]
+	switch len(coord) {
+	case 0:
+		add(ErrNoCoordinator, -1)
+	case 1:
+	default:
+		add(ErrMultiCoordinators, -1, coord...)
+	}
+
+	ids := make([]int, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if names := byID[uint8(id)]; len(names) > 1 && id != PLCACoordinatorID {
+			add(ErrDuplicateNodeID, id, names...)
+		}
+	}
+
+	if len(coord) != 1 {
+		return list
+	}
+	count := coordinatorCount(nodes)
+	for _, id := range ids {
+		if id >= int(count) && id != PLCAUnconfiguredID {
+			add(ErrNodeIDNotCovered, id, byID[uint8(id)]...)
+		}
+	}
+	for i := range nodes {
+		n := &nodes[i]
+		if p := n.PLCA; p != nil && p.NodeID != PLCACoordinatorID && p.NodeCount != count {
+			add(ErrNodeCountMismatch, int(p.NodeID), n.Name)
+		}
+	}
+	return list
+}
+
+func coordinatorCount(nodes []SegmentNode) uint8 {
+	for i := range nodes {
+		if p := nodes[i].PLCA; p != nil && p.NodeID == PLCACoordinatorID {
+			return p.NodeCount
+		}
+	}
+	return 0
+}
+
+// SegmentGaps returns the node IDs below the coordinator's node count
+// that are not used by any node. Each of them delays the PLCA cycle
+// by a transmit opportunity timeout. If there is no coordinator,
+// nil is returned.
+func SegmentGaps(nodes []SegmentNode) []int {
+	count := int(coordinatorCount(nodes))
+	if count == 0 {
+		return nil
+	}
+	used := make([]bool, count)
+	for i := range nodes {
+		if p := nodes[i].PLCA; p != nil && int(p.NodeID) < count {
+			used[p.NodeID] = true
+		}
+	}
+	var gaps []int
+	for id, u := range used {
+		if !u {
+			gaps = append(gaps, id)
+		}
+	}
+	return gaps
+}
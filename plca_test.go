@@ -0,0 +1,153 @@
+package t1s_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/knieriem/t1s"
+)
+
+func TestPLCAConfValidate(t *testing.T) {
+	for _, tc := range []struct {
+		conf  t1s.PLCAConf
+		field string
+		err   error
+	}{
+		{t1s.PLCAConf{NodeID: 0, NodeCount: 8}, "", nil},
+		{t1s.PLCAConf{NodeID: 7, NodeCount: 8}, "", nil},
+		{t1s.PLCAConf{NodeID: 1, NodeCount: 8, BurstCount: 2, BurstTimer: 96}, "", nil},
+		{t1s.PLCAConf{NodeID: 255, NodeCount: 8}, "NodeID", t1s.ErrPLCANodeID},
+		{t1s.PLCAConf{NodeID: 0, NodeCount: 0}, "NodeCount", t1s.ErrPLCANodeCount},
+		{t1s.PLCAConf{NodeID: 8, NodeCount: 8}, "NodeID", t1s.ErrPLCANodeIDRange},
+		{t1s.PLCAConf{NodeID: 1, NodeCount: 8, BurstCount: 1, BurstTimer: 95}, "BurstTimer", t1s.ErrPLCABurstTimer},
+		{t1s.PLCAConf{NodeID: 1, NodeCount: 8, BurstTimer: 0}, "", nil},
+	} {
+		err := tc.conf.Validate()
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%+v: error %v, want %v", tc.conf, err, tc.err)
+			continue
+		}
+		var cerr *t1s.PLCAConfError
+		if errors.As(err, &cerr) && cerr.Field != tc.field {
+			t.Errorf("%+v: field %q, want %q", tc.conf, cerr.Field, tc.field)
+		}
+	}
+}
+
+func plca(id, count uint8) *t1s.PLCAConf {
+	return &t1s.PLCAConf{NodeID: id, NodeCount: count}
+}
+
+func TestCheckSegment(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		nodes []t1s.SegmentNode
+		want  []string
+	}{
+		{"ok", []t1s.SegmentNode{
+			{"a", plca(0, 3)},
+			{"b", plca(1, 3)},
+			{"c", plca(2, 3)},
+		}, nil},
+		{"csma only", []t1s.SegmentNode{
+			{"a", nil},
+			{"b", nil},
+		}, nil},
+		{"duplicate", []t1s.SegmentNode{
+			{"a", plca(0, 3)},
+			{"b", plca(1, 3)},
+			{"c", plca(1, 3)},
+		}, []string{
+			"b, c: duplicate PLCA node ID: 1",
+		}},
+		{"no coordinator", []t1s.SegmentNode{
+			{"a", plca(1, 3)},
+			{"b", plca(2, 3)},
+		}, []string{
+			"no PLCA coordinator",
+		}},
+		{"multiple coordinators", []t1s.SegmentNode{
+			{"a", plca(0, 3)},
+			{"b", plca(0, 3)},
+			{"c", plca(1, 3)},
+		}, []string{
+			"a, b: more than one PLCA coordinator",
+		}},
+		{"not covered", []t1s.SegmentNode{
+			{"a", plca(0, 2)},
+			{"b", plca(1, 2)},
+			{"c", plca(4, 5)},
+		}, []string{
+			"c: PLCA node ID not covered by the coordinator's node count: 4",
+			"c: PLCA node count differs from the coordinator's: 4",
+		}},
+		{"count mismatch", []t1s.SegmentNode{
+			{"a", plca(0, 4)},
+			{"b", plca(1, 4)},
+			{"c", plca(2, 3)},
+		}, []string{
+			"c: PLCA node count differs from the coordinator's: 2",
+		}},
+		{"csma on plca segment", []t1s.SegmentNode{
+			{"a", plca(0, 2)},
+			{"b", nil},
+			{"c", plca(1, 2)},
+			{"d", nil},
+		}, []string{
+			"b, d: PLCA disabled on a PLCA segment",
+		}},
+		{"invalid", []t1s.SegmentNode{
+			{"a", plca(0, 2)},
+			{"b", plca(1, 0)},
+		}, []string{
+			"b: plca: NodeCount: node count must be in 1..255",
+			"b: PLCA node count differs from the coordinator's: 1",
+		}},
+		{"unconfigured", []t1s.SegmentNode{
+			{"a", plca(0, 2)},
+			{"b", plca(1, 2)},
+			{"c", plca(255, 2)},
+		}, []string{
+			"c: plca: NodeID: node ID 255 is reserved for unconfigured nodes",
+		}},
+	} {
+		var got []string
+		for _, c := range t1s.CheckSegment(tc.nodes) {
+			got = append(got, c.Error())
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: conflicts\n\t%q\nwant\n\t%q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSegmentGaps(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		nodes []t1s.SegmentNode
+		want  []int
+	}{
+		{"none", []t1s.SegmentNode{
+			{"a", plca(0, 2)},
+			{"b", plca(1, 2)},
+		}, nil},
+		{"gaps", []t1s.SegmentNode{
+			{"a", plca(0, 6)},
+			{"b", plca(2, 6)},
+			{"c", plca(3, 6)},
+			{"d", nil},
+		}, []int{1, 4, 5}},
+		{"beyond count", []t1s.SegmentNode{
+			{"a", plca(0, 2)},
+			{"b", plca(5, 8)},
+		}, []int{1}},
+		{"no coordinator", []t1s.SegmentNode{
+			{"a", plca(1, 4)},
+		}, nil},
+	} {
+		if got := t1s.SegmentGaps(tc.nodes); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: gaps %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
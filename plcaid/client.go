@@ -0,0 +1,311 @@
+package plcaid
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/knieriem/t1s"
+)
+
+// State is the state of a Client.
+type State uint8
+
+const (
+	StateInit       State = iota // not yet polled
+	StateRequesting              // using CSMA/CD, requesting a node ID
+	StateBound                   // using PLCA with a leased node ID
+	StateFallback                // no server replied; using the fallback settings
+)
+
+var stateNames = [...]string{
+	StateInit:       "init",
+	StateRequesting: "requesting",
+	StateBound:      "bound",
+	StateFallback:   "fallback",
+}
+
+func (s State) String() string {
+	if int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return "unknown"
+}
+
+// Client requests a node ID from a Server, and applies it using SetPLCA.
+// The driver must be initialized with PLCA disabled.
+//
+// All methods except Status must be called from the goroutine
+// running the driver, usually within its Service method.
+type Client struct {
+	MAC *t1s.MACConf
+
+	// SetPLCA applies PLCA settings, like method SetPLCA of lan865x.Inst.
+	SetPLCA func(enable bool, nodeID, nodeCount uint8) error
+
+	// OnChange, if set, is called after the PLCA settings have
+	// been changed; c is nil if CSMA/CD is used.
+	OnChange func(c *t1s.PLCAConf)
+
+	// Store, if set, is used to request the node ID leased
+	// last, and to save a new lease.
+	Store LeaseStore
+
+	// Fallback, if set, is applied if no server replied after the
+	// configured number of retries, so that a node may, for instance,
+	// use a preconfigured node ID; otherwise CSMA/CD remains in use.
+	// Requests are continued at a lower rate.
+	Fallback *t1s.PLCAConf
+
+	// RetryInterval is the interval of requests; if zero,
+	// DefaultRetryInterval is used. After Retries requests, or
+	// DefaultRetries, if Retries is zero, the client falls back.
+	RetryInterval time.Duration
+	Retries       int
+
+	Ticks t1s.TicksProvider
+	Next  t1s.UpperProto
+
+	state    State
+	xid      uint32
+	server   [t1s.AddrLen]byte
+	want     uint8 // node ID requested
+	plca     t1s.PLCAConf
+	tries    int
+	released bool
+	next     uint32 // time of the next request
+	renewAt  uint32
+	expires  uint32
+	out      []outMsg
+
+	status    atomic.Uint32
+	conflicts atomic.Uint32
+	failures  atomic.Uint32
+}
+
+// Status returns the client's state, and the PLCA settings in use,
+// which are nil if CSMA/CD is used. It may be called from any goroutine.
+func (c *Client) Status() (State, *t1s.PLCAConf) {
+	st := c.status.Load()
+	s := State(st)
+	if st&statusPLCA == 0 {
+		return s, nil
+	}
+	return s, &t1s.PLCAConf{NodeID: uint8(st >> 8), NodeCount: uint8(st >> 16)}
+}
+
+// Conflicts returns the number of conflicts detected.
+// It may be called from any goroutine.
+func (c *Client) Conflicts() int {
+	return int(c.conflicts.Load())
+}
+
+// Failures returns the number of calls of SetPLCA that failed.
+// It may be called from any goroutine.
+func (c *Client) Failures() int {
+	return int(c.failures.Load())
+}
+
+const statusPLCA = 1 << 24
+
+func (c *Client) setStatus(plca *t1s.PLCAConf) {
+	st := uint32(c.state)
+	if plca != nil {
+		st |= statusPLCA | uint32(plca.NodeID)<<8 | uint32(plca.NodeCount)<<16
+	}
+	c.status.Store(st)
+}
+
+// Release makes the client release its node ID, if it has one,
+// switch to CSMA/CD, and stop requesting IDs.
+func (c *Client) Release() {
+	if c.state == StateBound {
+		c.send(c.server, opRelease, c.plca.NodeID, 0)
+	}
+	c.apply(nil)
+	c.state = StateInit
+	c.released = true
+	c.setStatus(nil)
+}
+
+// PollForEth advances the client's timers, and returns a pending
+// message; otherwise c.Next is polled.
+func (c *Client) PollForEth(buf []byte) (int, error) {
+	c.step(c.Ticks.Milliseconds())
+	if len(c.out) != 0 {
+		m := c.out[0]
+		c.out = append(c.out[:0], c.out[1:]...)
+		return m.put(buf, m.dst, c.MAC.Addr)
+	}
+	if c.Next == nil {
+		return 0, nil
+	}
+	return c.Next.PollForEth(buf)
+}
+
+func (c *Client) step(now uint32) {
+	switch c.state {
+	case StateInit:
+		if c.released {
+			return
+		}
+		c.want = t1s.PLCAUnconfiguredID
+		if c.Store != nil {
+			leases, _ := c.Store.Load()
+			for _, l := range leases {
+				if l.Addr == c.MAC.Addr {
+					c.want = l.NodeID
+				}
+			}
+		}
+		c.request(now)
+	case StateRequesting, StateFallback:
+		if due(now, c.next) {
+			c.request(now)
+		}
+	case StateBound:
+		if due(now, c.expires) {
+			c.want = c.plca.NodeID
+			c.apply(nil)
+			c.request(now)
+			return
+		}
+		if due(now, c.renewAt) {
+			c.xid++
+			c.send(t1s.BroadcastAddr, opRequest, c.plca.NodeID, 0)
+			c.renewAt = now + ms(c.retryInterval())
+		}
+	}
+}
+
+// request sends a request, and falls back
+// after the configured number of retries.
+func (c *Client) request(now uint32) {
+	if c.state != StateRequesting && c.state != StateFallback {
+		c.state = StateRequesting
+		c.tries = 0
+		c.setStatus(nil)
+	}
+	c.xid++
+	c.send(t1s.BroadcastAddr, opRequest, c.want, 0)
+	c.tries++
+	retries := c.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+	if c.state == StateRequesting && c.tries > retries {
+		// If the fallback settings cannot be applied, the client
+		// stays in CSMA/CD, and tries again with the next request.
+		if c.apply(c.Fallback) == nil {
+			c.state = StateFallback
+			c.setStatus(c.Fallback)
+		}
+	}
+	if c.state == StateFallback {
+		c.next = now + ms(fallbackRetryInterval)
+	} else {
+		c.next = now + ms(c.retryInterval())
+	}
+}
+
+func (c *Client) retryInterval() time.Duration {
+	if c.RetryInterval == 0 {
+		return DefaultRetryInterval
+	}
+	return c.RetryInterval
+}
+
+func (c *Client) send(dst [t1s.AddrLen]byte, op byte, id uint8, count uint8) {
+	c.out = append(c.out, outMsg{dst: dst, msg: msg{op: op, xid: c.xid, id: id, count: count}})
+}
+
+// apply passes settings p to SetPLCA; nil means CSMA/CD.
+// Failures are counted, so that callers switching to CSMA/CD,
+// which have no alternative, may ignore the error.
+func (c *Client) apply(p *t1s.PLCAConf) error {
+	var err error
+	if p == nil {
+		err = c.SetPLCA(false, 0, 0)
+	} else {
+		err = c.SetPLCA(true, p.NodeID, p.NodeCount)
+	}
+	if err != nil {
+		c.failures.Add(1)
+		return err
+	}
+	if c.OnChange != nil {
+		c.OnChange(p)
+	}
+	return nil
+}
+
+// SendEthUp processes replies from the server, and announcements
+// of other clients; other frames are passed to c.Next.
+func (c *Client) SendEthUp(pkt []byte) error {
+	f, m, ok, err := frame(pkt)
+	if !ok {
+		if c.Next == nil {
+			return nil
+		}
+		return c.Next.SendEthUp(pkt)
+	}
+	if err != nil {
+		return err
+	}
+	now := c.Ticks.Milliseconds()
+	src := f.Src()
+	switch m.op {
+	case opAck:
+		if f.Dst() == c.MAC.Addr && m.xid == c.xid {
+			c.bind(now, src, &m)
+		}
+	case opNak:
+		if f.Dst() != c.MAC.Addr || m.xid != c.xid {
+			break
+		}
+		c.want = t1s.PLCAUnconfiguredID
+		if c.state == StateBound {
+			c.apply(nil)
+			c.state = StateInit
+			c.request(now)
+		}
+	case opAnnounce:
+		if c.state == StateBound && src != c.MAC.Addr && m.id == c.plca.NodeID {
+			c.conflict(now)
+		}
+	}
+	return nil
+}
+
+// bind applies the node ID assigned by the server.
+func (c *Client) bind(now uint32, server [t1s.AddrLen]byte, m *msg) {
+	p := t1s.PLCAConf{NodeID: m.id, NodeCount: m.count}
+	if p.Validate() != nil {
+		return
+	}
+	c.server = server
+	if c.state != StateBound || p != c.plca {
+		if err := c.apply(&p); err != nil {
+			return
+		}
+		if c.Store != nil {
+			c.Store.Save([]Lease{{Addr: c.MAC.Addr, NodeID: p.NodeID}})
+		}
+	}
+	c.state = StateBound
+	c.plca = p
+	c.expires = now + m.lease*1000
+	c.renewAt = now + m.lease*1000/2
+	c.setStatus(&p)
+	c.send(t1s.BroadcastAddr, opAnnounce, p.NodeID, p.NodeCount)
+}
+
+// conflict declines the node ID in use,
+// and requests a new one using CSMA/CD.
+func (c *Client) conflict(now uint32) {
+	c.conflicts.Add(1)
+	c.send(c.server, opDecline, c.plca.NodeID, 0)
+	c.apply(nil)
+	c.state = StateInit
+	c.want = t1s.PLCAUnconfiguredID
+	c.request(now)
+}
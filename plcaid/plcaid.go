@@ -0,0 +1,144 @@
+// Package plcaid implements a protocol assigning PLCA node IDs
+// automatically. A [Server], running on the coordinator (node 0),
+// leases node IDs to [Client] nodes, which start using CSMA/CD,
+// request an ID, and switch to PLCA once it has been assigned.
+//
+// A client announces its ID after switching to PLCA. If another node
+// announces the same ID, or the server finds the ID leased to a
+// different node, the client declines the ID, switches back to
+// CSMA/CD, and requests a new one. Leases may be persisted
+// using a [LeaseStore], so that nodes keep their IDs across restarts.
+//
+// Server and Client are [t1s.UpperProto] adapters; frames of other
+// protocols are passed to and polled from their Next upper layer.
+package plcaid
+
+import (
+	"errors"
+	"time"
+
+	"github.com/knieriem/t1s"
+)
+
+// EtherType is the EtherType of the protocol's messages.
+const EtherType = t1s.EtherTypeLocalExp1
+
+const (
+	DefaultLease         = time.Hour
+	DefaultRetryInterval = 500 * time.Millisecond
+	DefaultRetries       = 6
+
+	// fallbackRetryInterval is the interval of requests
+	// after a client has fallen back.
+	fallbackRetryInterval = 10 * time.Second
+)
+
+var ErrMalformed = errors.New("plcaid: malformed message")
+
+// Lease binds a node ID to the MAC address of a node.
+type Lease struct {
+	Addr   [t1s.AddrLen]byte
+	NodeID uint8
+}
+
+// LeaseStore persists leases. A client saves its own lease,
+// a server all leases it has granted. Save is called
+// from within the UpperProto methods of the client or server.
+type LeaseStore interface {
+	Load() ([]Lease, error)
+	Save(leases []Lease) error
+}
+
+const (
+	version = 1
+
+	opRequest  = 1 // client to broadcast: requested node ID, or 255
+	opAck      = 2 // server to client: node ID, node count, lease time
+	opNak      = 3 // server to client: no ID available, or ID not valid
+	opAnnounce = 4 // client to broadcast: node ID in use
+	opDecline  = 5 // client to server: node ID in conflict
+	opRelease  = 6 // client to server: node ID no longer used
+
+	// magic, version, op, transaction ID, node ID, node count, lease time
+	msgLen = 4 + 1 + 1 + 4 + 1 + 1 + 4
+)
+
+var magic = [4]byte{'T', '1', 'S', 'A'}
+
+type msg struct {
+	op    byte
+	xid   uint32
+	id    uint8
+	count uint8
+	lease uint32 // seconds
+}
+
+// put writes m, contained in a frame sent from src to dst, to buf.
+func (m *msg) put(buf []byte, dst, src [t1s.AddrLen]byte) (int, error) {
+	h := t1s.Header{Dst: dst, Src: src, EtherType: EtherType}
+	n, err := h.Put(buf)
+	if err != nil {
+		return 0, err
+	}
+	b := buf[n:]
+	if len(b) < msgLen {
+		return 0, t1s.ErrShortBuffer
+	}
+	copy(b, magic[:])
+	b[4] = version
+	b[5] = m.op
+	putBE32(b[6:], m.xid)
+	b[10] = m.id
+	b[11] = m.count
+	putBE32(b[12:], m.lease)
+	return t1s.Pad(buf, n+msgLen)
+}
+
+// parse decodes a message from the payload of a frame.
+func (m *msg) parse(p []byte) error {
+	if len(p) < msgLen || [4]byte(p[:4]) != magic || p[4] != version {
+		return ErrMalformed
+	}
+	m.op = p[5]
+	m.xid = be32(p[6:])
+	m.id = p[10]
+	m.count = p[11]
+	m.lease = be32(p[12:])
+	return nil
+}
+
+// frame returns the message contained in pkt, if its EtherType
+// matches; ok is false if the frame belongs to another protocol.
+func frame(pkt []byte) (f t1s.Frame, m msg, ok bool, err error) {
+	f = t1s.Frame(pkt)
+	if !f.Valid() || f.EtherType() != EtherType {
+		return f, m, false, nil
+	}
+	return f, m, true, m.parse(f.Payload())
+}
+
+// outMsg is a message waiting for transmission.
+type outMsg struct {
+	dst [t1s.AddrLen]byte
+	msg
+}
+
+// due reports whether time t has been reached.
+func due(now, t uint32) bool {
+	return int32(now-t) >= 0
+}
+
+func ms(d time.Duration) uint32 {
+	return uint32(d / time.Millisecond)
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func putBE32(b []byte, v uint32) {
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+}
@@ -0,0 +1,234 @@
+package plcaid
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/knieriem/t1s"
+	"github.com/knieriem/t1s/bussim"
+)
+
+type segment struct {
+	t   *testing.T
+	bus *bussim.Bus
+	srv *Server
+}
+
+func newSegment(t *testing.T, nodeCount uint8) *segment {
+	s := &segment{t: t, bus: bussim.New(1)}
+	s.srv = &Server{
+		MAC:       &t1s.MACConf{Addr: addr(0)},
+		NodeCount: nodeCount,
+		Ticks:     s.bus,
+	}
+	return s
+}
+
+func addr(i int) [t1s.AddrLen]byte {
+	return [t1s.AddrLen]byte{0x02, 0, 0, 0, 0, byte(i)}
+}
+
+// attachServer attaches the server as PLCA coordinator.
+func (s *segment) attachServer() *bussim.Port {
+	plca := &t1s.PLCAConf{NodeID: t1s.PLCACoordinatorID, NodeCount: s.srv.NodeCount}
+	return s.bus.AttachUpper("server", s.srv.MAC, plca, s.srv)
+}
+
+// attachClient attaches a client using CSMA/CD, until
+// it applies the PLCA settings leased by the server.
+func (s *segment) attachClient(i int, fallback *t1s.PLCAConf) (*Client, *bussim.Port) {
+	var p *bussim.Port
+	c := &Client{
+		MAC: &t1s.MACConf{Addr: addr(i)},
+		SetPLCA: func(enable bool, nodeID, nodeCount uint8) error {
+			if !enable {
+				p.Configure(nil)
+				return nil
+			}
+			p.Configure(&t1s.PLCAConf{NodeID: nodeID, NodeCount: nodeCount})
+			return nil
+		},
+		Fallback:      fallback,
+		RetryInterval: 100 * time.Millisecond,
+		Retries:       3,
+		Ticks:         s.bus,
+	}
+	p = s.bus.AttachUpper("client", c.MAC, nil, c)
+	return c, p
+}
+
+func (s *segment) run(d time.Duration) {
+	for end := s.bus.Now() + d; s.bus.Now() < end; {
+		s.bus.Advance(time.Millisecond)
+	}
+}
+
+// bound checks that c is bound, and returns its node ID.
+func (s *segment) bound(c *Client, p *bussim.Port) uint8 {
+	s.t.Helper()
+	st, plca := c.Status()
+	if st != StateBound || plca == nil {
+		s.t.Fatalf("client %x: state %v, plca %v; want bound", c.MAC.Addr, st, plca)
+	}
+	if plca.NodeCount != s.srv.NodeCount {
+		s.t.Errorf("client %x: node count %d, want %d", c.MAC.Addr, plca.NodeCount, s.srv.NodeCount)
+	}
+	if !p.PLCAStatus() {
+		s.t.Errorf("client %x: PLCA not active", c.MAC.Addr)
+	}
+	return plca.NodeID
+}
+
+func TestBind(t *testing.T) {
+	s := newSegment(t, 8)
+	s.attachServer()
+	var clients []*Client
+	var ports []*bussim.Port
+	for i := 1; i <= 3; i++ {
+		c, p := s.attachClient(i, nil)
+		clients = append(clients, c)
+		ports = append(ports, p)
+	}
+	s.run(2 * time.Second)
+
+	ids := make(map[uint8]bool)
+	for i, c := range clients {
+		id := s.bound(c, ports[i])
+		if id == t1s.PLCACoordinatorID || id >= 8 || ids[id] {
+			t.Errorf("client %d: node ID %d invalid, or not unique", i, id)
+		}
+		ids[id] = true
+	}
+	leases := s.srv.Leases()
+	if len(leases) != 3 {
+		t.Fatalf("%d leases, want 3", len(leases))
+	}
+	for _, l := range leases {
+		if !l.Active || !ids[l.NodeID] {
+			t.Errorf("unexpected lease %+v", l)
+		}
+	}
+}
+
+func TestConflict(t *testing.T) {
+	s := newSegment(t, 8)
+	s.attachServer()
+	c, p := s.attachClient(1, nil)
+	s.run(time.Second)
+	id := s.bound(c, p)
+
+	// Another node, not using the protocol, announces the same ID.
+	var buf [64]byte
+	m := msg{op: opAnnounce, id: id, count: 8}
+	n, err := m.put(buf[:], t1s.BroadcastAddr, addr(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rogue := s.bus.Attach("rogue", nil)
+	rogue.Send(buf[:n])
+	s.run(time.Second)
+
+	if n := c.Conflicts(); n != 1 {
+		t.Errorf("%d conflicts, want 1", n)
+	}
+	newID := s.bound(c, p)
+	if newID == id {
+		t.Errorf("client still bound to declined node ID %d", id)
+	}
+	for _, l := range s.srv.Leases() {
+		if l.NodeID == id {
+			t.Errorf("declined node ID %d still leased: %+v", id, l)
+		}
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	// There is only node ID 1 to be leased.
+	s := newSegment(t, 2)
+	s.srv.Lease = 2 * time.Second
+	srvPort := s.attachServer()
+	c1, p1 := s.attachClient(1, nil)
+	s.run(500 * time.Millisecond)
+	if id := s.bound(c1, p1); id != 1 {
+		t.Fatalf("client 1: node ID %d, want 1", id)
+	}
+
+	// The lease is renewed while client 1 is attached.
+	c2, p2 := s.attachClient(2, nil)
+	s.run(3 * time.Second)
+	s.bound(c1, p1)
+	if st, _ := c2.Status(); st == StateBound {
+		t.Fatal("client 2 bound, although no ID is available")
+	}
+
+	// Once client 1 is gone, and its lease has expired,
+	// node ID 1 is leased to client 2.
+	s.bus.Detach(p1)
+	s.run(15 * time.Second)
+	if st, plca := c2.Status(); st != StateBound || plca.NodeID != 1 {
+		t.Fatalf("client 2: state %v, plca %v; want bound to 1", st, plca)
+	}
+	leases := s.srv.Leases()
+	if len(leases) != 1 || leases[0].Addr != addr(2) || !leases[0].Active {
+		t.Errorf("leases: %+v", leases)
+	}
+
+	// Without the server, client 2 switches back
+	// to CSMA/CD once its lease has expired.
+	s.bus.Detach(srvPort)
+	s.run(3 * time.Second)
+	if st, plca := c2.Status(); st == StateBound || plca != nil {
+		t.Errorf("client 2: state %v, plca %v; want CSMA/CD", st, plca)
+	}
+	if p2.PLCAStatus() {
+		t.Error("client 2: PLCA still active")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	s := newSegment(t, 8)
+	fallback := &t1s.PLCAConf{NodeID: 5, NodeCount: 8}
+	c, p := s.attachClient(1, fallback)
+	s.run(time.Second)
+	st, plca := c.Status()
+	if st != StateFallback || plca == nil || *plca != *fallback {
+		t.Fatalf("state %v, plca %v; want fallback to %v", st, plca, fallback)
+	}
+
+	// Requests continue at a lower rate, so that
+	// the client binds once a server is present.
+	s.attachServer()
+	s.run(fallbackRetryInterval + time.Second)
+	s.bound(c, p)
+}
+
+func TestFallbackFailure(t *testing.T) {
+	s := newSegment(t, 8)
+	fallback := &t1s.PLCAConf{NodeID: 5, NodeCount: 8}
+	c, p := s.attachClient(1, fallback)
+	setPLCA := c.SetPLCA
+	c.SetPLCA = func(enable bool, nodeID, nodeCount uint8) error {
+		if enable {
+			return errors.New("failed")
+		}
+		return setPLCA(enable, nodeID, nodeCount)
+	}
+	s.run(time.Second)
+	if st, plca := c.Status(); st != StateRequesting || plca != nil {
+		t.Fatalf("state %v, plca %v; want requesting using CSMA/CD", st, plca)
+	}
+	if p.PLCAStatus() {
+		t.Error("PLCA active")
+	}
+	if c.Failures() == 0 {
+		t.Error("failures not counted")
+	}
+
+	// Once SetPLCA succeeds, the fallback settings are applied.
+	c.SetPLCA = setPLCA
+	s.run(time.Second)
+	if st, plca := c.Status(); st != StateFallback || plca == nil || *plca != *fallback {
+		t.Fatalf("state %v, plca %v; want fallback to %v", st, plca, fallback)
+	}
+}
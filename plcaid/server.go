@@ -0,0 +1,337 @@
+package plcaid
+
+import (
+	"sync"
+	"time"
+
+	"github.com/knieriem/t1s"
+)
+
+// DefaultDeclineHold is used if Server.DeclineHold is zero.
+const DefaultDeclineHold = 10 * time.Minute
+
+// Server leases node IDs in the range 1 .. NodeCount-1 to clients.
+// A node keeps its ID, even after its lease has expired,
+// until the ID is needed for another node.
+//
+// UpperProto methods must be called from the goroutine running
+// the driver; Leases may be called from any goroutine.
+type Server struct {
+	MAC *t1s.MACConf
+
+	// NodeCount is the node count of the coordinator,
+	// which is passed to the clients.
+	NodeCount uint8
+
+	// Lease is the duration of leases; if zero, DefaultLease is used.
+	Lease time.Duration
+
+	// Static contains node IDs reserved for certain nodes, which may
+	// or may not use the protocol; they are not leased to other nodes.
+	Static []Lease
+
+	// DeclineHold is the duration a node ID, declined by a client
+	// because of a conflict, is not leased again; if zero,
+	// DefaultDeclineHold is used.
+	DeclineHold time.Duration
+
+	// Store, if set, is used to load the leases at the first poll,
+	// and to save them whenever a node ID is leased to another node.
+	Store LeaseStore
+
+	Ticks t1s.TicksProvider
+	Next  t1s.UpperProto
+
+	mu       sync.Mutex
+	loaded   bool
+	bindings []binding
+	declined map[uint8]uint32 // node ID, end of hold time
+	out      []outMsg
+}
+
+type binding struct {
+	Lease
+	expires uint32
+	active  bool // false if released, or loaded from the store
+}
+
+func (b *binding) isActive(now uint32) bool {
+	return b.active && !due(now, b.expires)
+}
+
+// expiredBefore reports whether b expired before c;
+// both must not be active.
+func (b *binding) expiredBefore(c *binding) bool {
+	if !b.active || !c.active {
+		return !b.active && c.active
+	}
+	return int32(b.expires-c.expires) < 0
+}
+
+// LeaseInfo describes a lease granted by a server.
+type LeaseInfo struct {
+	Lease
+	Active    bool          // the lease has not expired
+	Remaining time.Duration // time until the lease expires
+}
+
+// Leases returns the leases granted by s, including expired ones,
+// ordered by node ID. It may be called from any goroutine.
+func (s *Server) Leases() []LeaseInfo {
+	now := s.Ticks.Milliseconds()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []LeaseInfo
+	for id := 1; id < int(s.NodeCount); id++ {
+		for i := range s.bindings {
+			b := &s.bindings[i]
+			if int(b.NodeID) != id {
+				continue
+			}
+			li := LeaseInfo{Lease: b.Lease}
+			if b.isActive(now) {
+				li.Active = true
+				li.Remaining = time.Duration(b.expires-now) * time.Millisecond
+			}
+			list = append(list, li)
+		}
+	}
+	return list
+}
+
+// PollForEth returns a pending reply; otherwise s.Next is polled.
+func (s *Server) PollForEth(buf []byte) (int, error) {
+	s.mu.Lock()
+	s.load()
+	var m outMsg
+	pending := len(s.out) != 0
+	if pending {
+		m = s.out[0]
+		s.out = append(s.out[:0], s.out[1:]...)
+	}
+	s.mu.Unlock()
+	if pending {
+		return m.put(buf, m.dst, s.MAC.Addr)
+	}
+	if s.Next == nil {
+		return 0, nil
+	}
+	return s.Next.PollForEth(buf)
+}
+
+// load reads the bindings from s.Store, once.
+func (s *Server) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	if s.Store == nil {
+		return
+	}
+	leases, err := s.Store.Load()
+	if err != nil {
+		return
+	}
+	for _, l := range leases {
+		if l.NodeID != t1s.PLCACoordinatorID && l.NodeID < s.NodeCount && s.lookupID(l.NodeID) == -1 {
+			s.bindings = append(s.bindings, binding{Lease: l})
+		}
+	}
+}
+
+func (s *Server) save() {
+	if s.Store == nil {
+		return
+	}
+	leases := make([]Lease, len(s.bindings))
+	for i := range s.bindings {
+		leases[i] = s.bindings[i].Lease
+	}
+	s.Store.Save(leases)
+}
+
+// SendEthUp processes messages of clients;
+// other frames are passed to s.Next.
+func (s *Server) SendEthUp(pkt []byte) error {
+	f, m, ok, err := frame(pkt)
+	if !ok {
+		if s.Next == nil {
+			return nil
+		}
+		return s.Next.SendEthUp(pkt)
+	}
+	if err != nil {
+		return err
+	}
+	now := s.Ticks.Milliseconds()
+	src := f.Src()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	switch m.op {
+	case opRequest:
+		id, ok := s.assign(now, src, m.id)
+		if !ok {
+			s.reply(src, &msg{op: opNak, xid: m.xid})
+			break
+		}
+		lease := uint32(s.leaseTime() / time.Second)
+		s.reply(src, &msg{op: opAck, xid: m.xid, id: id, count: s.NodeCount, lease: lease})
+	case opAnnounce:
+		if owner, ok := s.owner(m.id); !ok || owner != src {
+			s.reply(src, &msg{op: opNak, xid: m.xid, id: m.id})
+		}
+	case opDecline:
+		if f.Dst() != s.MAC.Addr {
+			break
+		}
+		if s.declined == nil {
+			s.declined = make(map[uint8]uint32)
+		}
+		hold := s.DeclineHold
+		if hold == 0 {
+			hold = DefaultDeclineHold
+		}
+		s.declined[m.id] = now + ms(hold)
+		if i := s.lookupID(m.id); i != -1 && s.bindings[i].Addr == src {
+			s.remove(i)
+		}
+	case opRelease:
+		if f.Dst() != s.MAC.Addr {
+			break
+		}
+		if i := s.lookupID(m.id); i != -1 && s.bindings[i].Addr == src {
+			s.bindings[i].active = false
+		}
+	}
+	return nil
+}
+
+func (s *Server) reply(dst [t1s.AddrLen]byte, m *msg) {
+	s.out = append(s.out, outMsg{dst: dst, msg: *m})
+}
+
+// assign determines the node ID for the node at addr, which requested
+// node ID want, and updates the bindings. The node gets, in this order,
+// its static ID, the ID it has been bound to, the ID requested,
+// the lowest free ID, or the ID of the binding that expired first.
+func (s *Server) assign(now uint32, addr [t1s.AddrLen]byte, want uint8) (uint8, bool) {
+	for _, l := range s.Static {
+		if l.Addr == addr {
+			return l.NodeID, true
+		}
+	}
+	i := s.lookupAddr(addr)
+	if i != -1 && s.usable(now, s.bindings[i].NodeID, addr) {
+		s.bindings[i].active = true
+		s.bindings[i].expires = now + ms(s.leaseTime())
+		return s.bindings[i].NodeID, true
+	}
+	if i != -1 {
+		s.remove(i)
+	}
+
+	id, ok := uint8(0), false
+	switch {
+	case s.usable(now, want, addr) && s.lookupID(want) == -1:
+		id, ok = want, true
+	default:
+		for n := 1; n < int(s.NodeCount); n++ {
+			if s.usable(now, uint8(n), addr) && s.lookupID(uint8(n)) == -1 {
+				id, ok = uint8(n), true
+				break
+			}
+		}
+	}
+	if !ok {
+		// Take over the binding that expired first.
+		k := -1
+		for j := range s.bindings {
+			b := &s.bindings[j]
+			if b.isActive(now) || !s.usable(now, b.NodeID, addr) {
+				continue
+			}
+			if k == -1 || b.expiredBefore(&s.bindings[k]) {
+				k = j
+			}
+		}
+		if k == -1 {
+			return 0, false
+		}
+		id = s.bindings[k].NodeID
+		s.remove(k)
+	}
+	s.bindings = append(s.bindings, binding{
+		Lease:   Lease{Addr: addr, NodeID: id},
+		expires: now + ms(s.leaseTime()),
+		active:  true,
+	})
+	s.save()
+	return id, true
+}
+
+// usable reports whether node ID id may be leased to the node at addr,
+// regardless of bindings of other nodes.
+func (s *Server) usable(now uint32, id uint8, addr [t1s.AddrLen]byte) bool {
+	if id == t1s.PLCACoordinatorID || id >= s.NodeCount {
+		return false
+	}
+	for _, l := range s.Static {
+		if l.NodeID == id && l.Addr != addr {
+			return false
+		}
+	}
+	if t, ok := s.declined[id]; ok {
+		if !due(now, t) {
+			return false
+		}
+		delete(s.declined, id)
+	}
+	return true
+}
+
+// owner returns the address of the node id is bound to,
+// or reserved for. If ok is false, the ID is not valid.
+func (s *Server) owner(id uint8) (addr [t1s.AddrLen]byte, ok bool) {
+	if id == t1s.PLCACoordinatorID || id >= s.NodeCount {
+		return addr, false
+	}
+	for _, l := range s.Static {
+		if l.NodeID == id {
+			return l.Addr, true
+		}
+	}
+	if i := s.lookupID(id); i != -1 {
+		return s.bindings[i].Addr, true
+	}
+	return addr, false
+}
+
+func (s *Server) leaseTime() time.Duration {
+	if s.Lease == 0 {
+		return DefaultLease
+	}
+	return s.Lease
+}
+
+func (s *Server) lookupAddr(addr [t1s.AddrLen]byte) int {
+	for i := range s.bindings {
+		if s.bindings[i].Addr == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) lookupID(id uint8) int {
+	for i := range s.bindings {
+		if s.bindings[i].NodeID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) remove(i int) {
+	s.bindings = append(s.bindings[:i], s.bindings[i+1:]...)
+}
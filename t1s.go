@@ -13,9 +13,34 @@ type UpperProto interface {
 	PollForEth(buf []byte) (n int, err error)
 }
 
+// TicksProvider provides a millisecond tick count,
+// the time base of drivers and protocol layers.
+type TicksProvider interface {
+	Milliseconds() uint32
+}
+
+// MACConf defines the MAC address and frame filter settings.
 type MACConf struct {
 	Addr [6]byte
 
+	// Multicast lists the multicast group addresses
+	// of frames that shall be received.
+	Multicast [][6]byte
+
+	// AllMulticast enables the reception of frames
+	// sent to any multicast address.
+	AllMulticast bool
+
+	// RejectBroadcast disables the reception of broadcast frames.
+	RejectBroadcast bool
+
+	// DiscardUnmatched requests a driver to drop frames that passed
+	// its filters only imprecisely, like multicast frames accepted because of
+	// a hash collision. Only frames sent to Addr, to the broadcast address,
+	// or to one of the addresses in Multicast are delivered then.
+	// DiscardUnmatched has no effect if CopyAllFrames is set.
+	DiscardUnmatched bool
+
 	CopyAllFrames bool
 
 	TxCutThrough bool
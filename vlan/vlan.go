@@ -0,0 +1,162 @@
+// Package vlan provides a [t1s.UpperProto] adapter connecting
+// upper layers to IEEE 802.1Q VLANs. Received frames are untagged,
+// and passed to the upper layer of their VLAN; frames transmitted
+// by an upper layer are tagged with the VLAN's ID and priority.
+package vlan
+
+import (
+	"errors"
+
+	"github.com/knieriem/t1s"
+)
+
+// MaxVID is the largest valid VLAN identifier.
+const MaxVID = 4094
+
+var (
+	ErrVID       = errors.New("vlan: invalid VLAN ID")
+	ErrDuplicate = errors.New("vlan: VLAN already added")
+	ErrShortPoll = errors.New("vlan: polled frame too short")
+)
+
+// VLAN connects an upper layer to a VLAN.
+type VLAN struct {
+	ID    uint16
+	Upper t1s.UpperProto
+
+	// Priority is the 802.1p priority code point (0..7)
+	// of tags inserted into transmitted frames. VLANs are polled
+	// for frames to be transmitted in the order of their priority.
+	Priority uint8
+
+	// Untagged makes frames be transmitted without a tag;
+	// their priority is lost then.
+	Untagged bool
+}
+
+// Stats contains counters of a Mux.
+type Stats struct {
+	RxUnknownVID uint64 // frames dropped since their VLAN has not been added
+	RxShort      uint64 // frames dropped because of an invalid header
+}
+
+// Mux distributes received frames to the upper layers of
+// VLANs, and collects frames to be transmitted from them.
+// The capacity of buffers passed to the upper layers by PollForEth
+// is reduced by the size of a tag. The upper layer of the PVID
+// receives untagged and priority tagged frames.
+type Mux struct {
+	PVID uint16
+
+	vlans []*VLAN
+	stats Stats
+}
+
+// Add connects v to m. The upper layers of all VLANs
+// must be added before m is used.
+func (m *Mux) Add(v *VLAN) error {
+	if v.ID == 0 || v.ID > MaxVID {
+		return ErrVID
+	}
+	if m.Lookup(v.ID) != nil {
+		return ErrDuplicate
+	}
+	// Keep m.vlans ordered by decreasing priority,
+	// VLANs of equal priority in the order they were added.
+	i := len(m.vlans)
+	for i > 0 && pcpRank(m.vlans[i-1].Priority) < pcpRank(v.Priority) {
+		i--
+	}
+	m.vlans = append(m.vlans, nil)
+	copy(m.vlans[i+1:], m.vlans[i:])
+	m.vlans[i] = v
+	return nil
+}
+
+// pcpRank maps priority code points to their order;
+// PCP 1 (background) ranks below PCP 0 (best effort).
+func pcpRank(pcp uint8) uint8 {
+	switch pcp & 7 {
+	case 0:
+		return 1
+	case 1:
+		return 0
+	}
+	return pcp & 7
+}
+
+// Lookup returns the VLAN with the given ID, or nil.
+func (m *Mux) Lookup(vid uint16) *VLAN {
+	for _, v := range m.vlans {
+		if v.ID == vid {
+			return v
+		}
+	}
+	return nil
+}
+
+// Stats returns the counters of m.
+func (m *Mux) Stats() Stats {
+	return m.stats
+}
+
+// SendEthUp removes the tag of a tagged frame in place,
+// and passes the frame to the upper layer of its VLAN.
+func (m *Mux) SendEthUp(pkt []byte) error {
+	f := t1s.Frame(pkt)
+	if !f.Valid() {
+		m.stats.RxShort++
+		return nil
+	}
+	vid := m.PVID
+	if tag, ok := f.VLANTag(); ok {
+		if tag.VID != 0 {
+			vid = tag.VID
+		}
+		copy(pkt[t1s.VLANTagLen:], pkt[:2*t1s.AddrLen])
+		pkt = pkt[t1s.VLANTagLen:]
+	}
+	v := m.Lookup(vid)
+	if v == nil {
+		m.stats.RxUnknownVID++
+		return nil
+	}
+	return v.Upper.SendEthUp(pkt)
+}
+
+// PollForEth polls the upper layers in the order of their priority,
+// and inserts a tag into the frame returned, unless the VLAN is untagged.
+func (m *Mux) PollForEth(buf []byte) (int, error) {
+	for _, v := range m.vlans {
+		if v.Untagged {
+			n, err := v.Upper.PollForEth(buf)
+			if err != nil || n != 0 {
+				return n, err
+			}
+			continue
+		}
+		n, err := v.Upper.PollForEth(buf[t1s.VLANTagLen:])
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			continue
+		}
+		if n < t1s.HeaderLen {
+			return 0, ErrShortPoll
+		}
+		f := t1s.Frame(buf[t1s.VLANTagLen:])
+		h := t1s.Header{
+			Dst:       f.Dst(),
+			Src:       f.Src(),
+			Tagged:    true,
+			VLAN:      t1s.VLANTag{PCP: v.Priority, VID: v.ID},
+			EtherType: t1s.EtherType(f[2*t1s.AddrLen])<<8 | t1s.EtherType(f[2*t1s.AddrLen+1]),
+		}
+		// The header overwrites the addresses and EtherType
+		// of the polled frame, which are copied to h already.
+		h.Put(buf)
+		return n + t1s.VLANTagLen, nil
+	}
+	return 0, nil
+}